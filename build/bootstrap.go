@@ -0,0 +1,7 @@
+package build
+
+// ConsensusSigningKey is the hex encoded Ed25519 public key that signs the
+// manifests of the bootstrapped consensus database archives. It can be pinned
+// via the Makefile when built. While it is empty the manifests do not have to
+// be signed, but their checksums are still verified.
+var ConsensusSigningKey string
@@ -4,4 +4,46 @@ const (
 	// EnvvarMetaDataDir is the environment variable that tells the web wallet where
 	// to put the sia data
 	EnvvarMetaDataDir = "SCPRIME_WEB_WALLET_DATA_DIR"
+
+	// EnvvarListenAddress is the environment variable that sets the address
+	// the web wallet's GUI is served on
+	EnvvarListenAddress = "SCPRIME_WEB_WALLET_LISTEN_ADDRESS"
+
+	// EnvvarRPCAddress is the environment variable that sets the address the
+	// gateway listens on for peers
+	EnvvarRPCAddress = "SCPRIME_WEB_WALLET_RPC_ADDRESS"
+
+	// EnvvarBootstrap is the environment variable that sets whether the
+	// gateway connects to the bootstrap peers
+	EnvvarBootstrap = "SCPRIME_WEB_WALLET_BOOTSTRAP"
+
+	// EnvvarBrowser is the environment variable that sets the browser the GUI
+	// is launched in
+	EnvvarBrowser = "SCPRIME_WEB_WALLET_BROWSER"
+
+	// EnvvarHeadless is the environment variable that, when set to true, runs
+	// the web wallet as a service without launching the GUI
+	EnvvarHeadless = "SCPRIME_WEB_WALLET_HEADLESS"
+
+	// EnvvarLogLevel is the environment variable that sets the lowest level of
+	// the messages that are logged
+	EnvvarLogLevel = "SCPRIME_WEB_WALLET_LOG_LEVEL"
+
+	// EnvvarAllowedHosts is the environment variable that holds a comma
+	// separated list of extra host names the web wallet may be reached by
+	EnvvarAllowedHosts = "SCPRIME_WEB_WALLET_ALLOWED_HOSTS"
+
+	// EnvvarListenExternal is the environment variable that, when set to true,
+	// lets the web wallet listen on all network interfaces instead of only
+	// loopback
+	EnvvarListenExternal = "SCPRIME_WEB_WALLET_LISTEN_EXTERNAL"
+
+	// EnvvarMetrics is the environment variable that, when set to true,
+	// serves Prometheus metrics at /metrics
+	EnvvarMetrics = "SCPRIME_WEB_WALLET_METRICS"
+
+	// EnvvarBootstrapMirrors is the environment variable that holds a comma
+	// separated list of the mirrors the consensus set is bootstrapped from,
+	// in the order they are tried
+	EnvvarBootstrapMirrors = "SCPRIME_WEB_WALLET_BOOTSTRAP_MIRRORS"
 )
@@ -1,23 +1,193 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
 	"gitlab.com/scpcorp/ScPrime/node"
 	"gitlab.com/scpcorp/webwallet/build"
+	"gitlab.com/scpcorp/webwallet/daemon"
+	"gitlab.com/scpcorp/webwallet/logger"
+	"gitlab.com/scpcorp/webwallet/modules/bootstrapper"
+	"gitlab.com/scpcorp/webwallet/server"
+)
+
+// Config keys. They double as the names of the command line flags and as the
+// keys of the config file.
+const (
+	keyListenAddress  = "listen-address"
+	keyRPCAddress     = "rpc-address"
+	keyBootstrap      = "bootstrap"
+	keyDataDir        = "data-dir"
+	keyBrowser        = "browser"
+	keyHeadless       = "headless"
+	keyLogLevel       = "log-level"
+	keyAllowedHosts   = "allowed-hosts"
+	keyListenExternal = "listen-external"
+	keyMetrics        = "metrics"
+	keyMirrors        = "bootstrap-mirrors"
 )
 
-// createNodeParams parses the provided config and creates the corresponding
-// node params for the server.
-func configNodeParams() node.NodeParams {
+// configFileName is the name of the config file in the data directory without
+// its extension. Any extension viper understands, such as .yaml, .toml or
+// .json, may be used.
+const configFileName = "scp-webwallet"
+
+// externalAddress is the address the server listens on when it may listen on
+// every interface and no listen address was configured.
+const externalAddress = ":4300"
+
+// envvars maps the config keys to the environment variables that override
+// them.
+var envvars = map[string]string{
+	keyListenAddress:  build.EnvvarListenAddress,
+	keyRPCAddress:     build.EnvvarRPCAddress,
+	keyBootstrap:      build.EnvvarBootstrap,
+	keyDataDir:        build.EnvvarMetaDataDir,
+	keyBrowser:        build.EnvvarBrowser,
+	keyHeadless:       build.EnvvarHeadless,
+	keyLogLevel:       build.EnvvarLogLevel,
+	keyAllowedHosts:   build.EnvvarAllowedHosts,
+	keyListenExternal: build.EnvvarListenExternal,
+	keyMetrics:        build.EnvvarMetrics,
+	keyMirrors:        build.EnvvarBootstrapMirrors,
+}
+
+// errUsage is wrapped by errors that are caused by invalid flags or settings.
+var errUsage = errors.New("invalid usage")
+
+// defineFlags defines the daemon's command line flags on the command.
+func defineFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.String(keyListenAddress, server.DefaultAddress, "address the GUI is served on")
+	flags.String(keyRPCAddress, ":4281", "address the gateway listens on for peers")
+	flags.Bool(keyBootstrap, true, "connect the gateway to the bootstrap peers")
+	flags.String(keyDataDir, build.ScPrimeWebWalletDir(), "directory the web wallet stores its data in")
+	flags.String(keyBrowser, "", "browser the GUI is launched in (default, chrome, chromium, edge, firefox or safari); asked for in the GUI when empty")
+	flags.Bool(keyHeadless, false, "run as a service without launching the GUI; the daemon and its wallets keep running when the browser is closed")
+	flags.String(keyLogLevel, "info", "lowest level of the messages that are logged (debug, info, warn or error)")
+	flags.StringSlice(keyAllowedHosts, nil, "extra host names the GUI may be reached by")
+	flags.Bool(keyListenExternal, false, "allow the GUI to be served on interfaces other than loopback")
+	flags.Bool(keyMetrics, false, "serve Prometheus metrics at /metrics")
+	flags.StringSlice(keyMirrors, []string{bootstrapper.DefaultMirror}, "base URLs of the mirrors the consensus set is bootstrapped from, in the order they are tried")
+}
+
+// loadConfig merges the command line flags, the environment variables and the
+// config file in the data directory, in that order of precedence.
+func loadConfig(cmd *cobra.Command) (*viper.Viper, error) {
+	v := viper.New()
+	err := v.BindPFlags(cmd.Flags())
+	if err != nil {
+		return nil, err
+	}
+	for key, envvar := range envvars {
+		err = v.BindEnv(key, envvar)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// The data directory holds the config file, so it can only be set by a
+	// flag or the environment.
+	dataDir := v.GetString(keyDataDir)
+	v.SetConfigName(configFileName)
+	v.AddConfigPath(dataDir)
+	err = v.ReadInConfig()
+	var notFound viper.ConfigFileNotFoundError
+	if err != nil && !errors.As(err, &notFound) {
+		return nil, fmt.Errorf("unable to read config file: %w", err)
+	}
+	v.Set(keyDataDir, dataDir)
+	return v, nil
+}
+
+// configDaemon creates the daemon config from the merged settings.
+func configDaemon(v *viper.Viper) (daemon.Config, error) {
+	logLevel, err := logger.ParseLevel(v.GetString(keyLogLevel))
+	if err != nil {
+		return daemon.Config{}, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	mirrors, err := configMirrors(v)
+	if err != nil {
+		return daemon.Config{}, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	return daemon.Config{
+		NodeParams:       configNodeParams(v),
+		ServerOptions:    configServerOptions(v),
+		Browser:          v.GetString(keyBrowser),
+		Headless:         v.GetBool(keyHeadless),
+		LogLevel:         logLevel,
+		BootstrapMirrors: mirrors,
+	}, nil
+}
+
+// configMirrors returns the bootstrap mirrors from the merged settings. Like
+// the allowed hosts they may be given as a list or as a comma separated
+// string.
+func configMirrors(v *viper.Viper) ([]string, error) {
+	var mirrors []string
+	for _, list := range v.GetStringSlice(keyMirrors) {
+		for _, mirror := range strings.Split(list, ",") {
+			mirror = strings.TrimSpace(mirror)
+			if mirror == "" {
+				continue
+			}
+			u, err := url.Parse(mirror)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("bootstrap mirror %q is not an http or https URL", mirror)
+			}
+			mirrors = append(mirrors, mirror)
+		}
+	}
+	return mirrors, nil
+}
+
+// configNodeParams creates the node params from the merged settings.
+func configNodeParams(v *viper.Viper) node.NodeParams {
 	params := node.NodeParams{}
 	// Set the modules.
 	params.CreateGateway = true
 	params.CreateConsensusSet = true
 	params.CreateTransactionPool = true
 	// Parse remaining fields.
-	params.Bootstrap = true // set to true when the gateway should use the bootstrap peer list
-	params.Dir = build.ScPrimeWebWalletDir()
+	params.Bootstrap = v.GetBool(keyBootstrap) // set to true when the gateway should use the bootstrap peer list
+	params.RPCAddress = v.GetString(keyRPCAddress)
+	params.Dir = v.GetString(keyDataDir)
 	params.CheckTokenExpirationFrequency = 1 * time.Hour // default
 	return params
 }
+
+// configServerOptions creates the server options from the merged settings.
+func configServerOptions(v *viper.Viper) []server.Option {
+	var opts []server.Option
+	// Allowed hosts may be given as a list or as a comma separated string.
+	for _, hosts := range v.GetStringSlice(keyAllowedHosts) {
+		for _, host := range strings.Split(hosts, ",") {
+			host = strings.TrimSpace(host)
+			if host != "" {
+				opts = append(opts, server.WithAllowedHosts(host))
+			}
+		}
+	}
+	addr := v.GetString(keyListenAddress)
+	// Only an address that was chosen on purpose is kept when it is in use.
+	if !v.IsSet(keyListenAddress) {
+		opts = append(opts, server.WithPortFallback())
+	}
+	if v.GetBool(keyListenExternal) {
+		if !v.IsSet(keyListenAddress) {
+			addr = externalAddress
+		}
+		opts = append(opts, server.WithExternalInterfaces())
+	}
+	opts = append(opts, server.WithAddress(addr))
+	if v.GetBool(keyMetrics) {
+		opts = append(opts, server.WithMetrics())
+	}
+	return opts
+}
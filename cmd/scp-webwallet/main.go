@@ -1,17 +1,21 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
+	"github.com/spf13/cobra"
+
 	"gitlab.com/scpcorp/webwallet/daemon"
 )
 
 // exit codes
 // inspired by sysexits.h
 const (
-	exitCodeGeneral = 1  // Not in sysexits.h, but is standard practice.
-	exitCodeUsage   = 64 // EX_USAGE in sysexits.h
+	exitCodeGeneral  = 1  // Not in sysexits.h, but is standard practice.
+	exitCodeUsage    = 64 // EX_USAGE in sysexits.h
+	exitCodeShutdown = 70 // EX_SOFTWARE in sysexits.h
 )
 
 // die prints its arguments to stderr, then exits the program with the default
@@ -21,16 +25,71 @@ func die(args ...interface{}) {
 	os.Exit(exitCodeGeneral)
 }
 
-// main starts the daemon.
-func main() {
-	// configure the the node params.
-	params := configNodeParams()
+// rootCmd returns the command that starts the daemon.
+func rootCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scp-webwallet",
+		Short: "ScPrime web wallet",
+		Long: "ScPrime web wallet serves a wallet GUI to the browser.\n\n" +
+			"Settings are read from the flags, then from the SCPRIME_WEB_WALLET_*\n" +
+			"environment variables, then from " + configFileName + ".yaml (or .toml, .json)\n" +
+			"in the data directory.",
+		Args:          noArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE:          runDaemon,
+	}
+	defineFlags(cmd)
+	cmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	})
+	return cmd
+}
+
+// noArgs rejects positional arguments as invalid usage.
+func noArgs(cmd *cobra.Command, args []string) error {
+	err := cobra.NoArgs(cmd, args)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	return nil
+}
+
+// runDaemon loads the config and starts the daemon.
+func runDaemon(cmd *cobra.Command, _ []string) error {
+	v, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+	config, err := configDaemon(v)
+	if err != nil {
+		return err
+	}
 	// Start the ScPrime web wallet daemon.
 	// the startDaemon method will only return when it is shutting down.
-	err := daemon.StartDaemon(&params)
+	err = daemon.StartDaemon(config)
 	if err != nil {
-		die(err)
+		return err
 	}
 	// Daemon seems to have closed cleanly. Print a 'closed' message.
 	fmt.Println("Shutdown complete.")
+	return nil
+}
+
+// main starts the daemon.
+func main() {
+	cmd := rootCmd()
+	err := cmd.Execute()
+	if errors.Is(err, errUsage) {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprint(os.Stderr, cmd.UsageString())
+		os.Exit(exitCodeUsage)
+	}
+	if errors.Is(err, daemon.ErrShutdown) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCodeShutdown)
+	}
+	if err != nil {
+		die(err)
+	}
 }
@@ -0,0 +1,30 @@
+package daemon
+
+import (
+	"gitlab.com/scpcorp/ScPrime/node"
+
+	"gitlab.com/scpcorp/webwallet/logger"
+	"gitlab.com/scpcorp/webwallet/server"
+)
+
+// Config holds the settings the daemon is started with.
+type Config struct {
+	// NodeParams are the parameters the node is loaded with. NodeParams.Dir
+	// is also the web wallet's data directory.
+	NodeParams node.NodeParams
+	// ServerOptions configure the HTTP server that serves the GUI.
+	ServerOptions []server.Option
+	// Browser is the browser the GUI is launched in. When it is empty the
+	// browser that was chosen in the GUI is used.
+	Browser string
+	// Headless runs the daemon as a service. The GUI is not launched and the
+	// daemon keeps running and its wallets stay loaded when the browser is
+	// closed.
+	Headless bool
+	// LogLevel is the lowest level of the messages that are logged.
+	LogLevel logger.Level
+	// BootstrapMirrors are the base URLs of the mirrors the consensus set is
+	// bootstrapped from, in the order they are tried. The default mirror is
+	// used when it is empty.
+	BootstrapMirrors []string
+}
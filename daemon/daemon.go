@@ -1,7 +1,10 @@
 package daemon
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -9,7 +12,10 @@ import (
 	"time"
 
 	"gitlab.com/scpcorp/webwallet/build"
+	"gitlab.com/scpcorp/webwallet/logger"
+	"gitlab.com/scpcorp/webwallet/modules/bootstrapper"
 	"gitlab.com/scpcorp/webwallet/modules/browserconfig"
+	consensusbuilder "gitlab.com/scpcorp/webwallet/modules/consensesbuilder"
 	"gitlab.com/scpcorp/webwallet/modules/launcher"
 	"gitlab.com/scpcorp/webwallet/server"
 
@@ -17,31 +23,51 @@ import (
 	"gitlab.com/scpcorp/ScPrime/node"
 )
 
-// printVersionAndRevision prints the daemon's version and revision numbers.
-func printVersionAndRevision() {
+// Log file settings. The log file is kept in the data directory.
+const (
+	logFileName    = "scp-webwallet.log"
+	maxLogFileSize = 10 << 20 // 10 MiB
+	maxLogFiles    = 5
+)
+
+// newLogger returns the logger that writes to stdout and to the rotating log
+// file in the data directory, and the log file so that it can be closed. When
+// the log file cannot be opened only stdout is used.
+func newLogger(config Config) (logger.Logger, io.Closer) {
+	file, err := logger.OpenFile(filepath.Join(config.NodeParams.Dir, logFileName), maxLogFileSize, maxLogFiles)
+	if err != nil {
+		log := logger.New(os.Stdout, config.LogLevel)
+		log.Warn("Unable to open the log file, logging to stdout only", logger.Err(err))
+		return log, io.NopCloser(nil)
+	}
+	return logger.New(io.MultiWriter(os.Stdout, file), config.LogLevel), file
+}
+
+// printVersionAndRevision logs the daemon's version and revision numbers.
+func printVersionAndRevision(log logger.Logger) {
 	if build.Version == "" {
-		fmt.Println("WARN: compiled ScPrime web wallet without version.")
+		log.Warn("Compiled ScPrime web wallet without version")
 	} else {
-		fmt.Println("ScPrime web wallet v" + build.Version)
+		log.Info("ScPrime web wallet v" + build.Version)
 	}
 	if build.GitRevision == "" {
-		fmt.Println("WARN: compiled ScPrime web wallet without version.")
+		log.Warn("Compiled ScPrime web wallet without Git revision")
 	} else {
-		fmt.Println("ScPrime web wallet Git revision " + build.GitRevision)
+		log.Info("ScPrime web wallet Git revision " + build.GitRevision)
 	}
 	if spdBuild.DEBUG {
-		fmt.Println("Running ScPrime daemon with debugging enabled")
+		log.Info("Running ScPrime daemon with debugging enabled")
 	}
 	if spdBuild.Version == "" {
-		fmt.Println("WARN: compiled ScPrime daemon without version.")
+		log.Warn("Compiled ScPrime daemon without version")
 	} else {
-		fmt.Println("ScPrime daemon v" + spdBuild.Version)
+		log.Info("ScPrime daemon v" + spdBuild.Version)
 	}
 }
 
 // installMmapSignalHandler installs a signal handler for Mmap related signals
 // and exits when such a signal is received.
-func installMmapSignalHandler() {
+func installMmapSignalHandler(log logger.Logger) {
 	// NOTE: ideally we would catch SIGSEGV here too, since that signal can
 	// also be thrown by an mmap I/O error. However, SIGSEGV can occur under
 	// other circumstances as well, and in those cases, we will want a full
@@ -50,8 +76,7 @@ func installMmapSignalHandler() {
 	signal.Notify(mmapChan, syscall.SIGBUS)
 	go func() {
 		<-mmapChan
-		fmt.Println("A fatal I/O exception (SIGBUS) has occurred.")
-		fmt.Println("Please check your disk for errors.")
+		log.Error("A fatal I/O exception (SIGBUS) has occurred, please check your disk for errors")
 		os.Exit(1)
 	}()
 }
@@ -65,83 +90,317 @@ func installKillSignalHandler() chan os.Signal {
 	return sigChan
 }
 
-func startNode(node *node.Node, params *node.NodeParams, loadStart time.Time) {
-	err := loadNode(node, params)
+// startNode loads the node and closes ready once its modules have been loaded.
+// When a module fails to load, or the consensus set fails after it was loaded,
+// the failure is shown in the GUI and startNode waits for the user to retry,
+// or to reset the consensus set, until stop is closed.
+func startNode(srv *server.Server, node *node.Node, params *node.NodeParams, sd *sdNotifier, log logger.Logger, loadStart time.Time, ready chan<- struct{}, stop <-chan struct{}) {
+	proceed, err := prepareNode(srv, node, params, sd, log)
 	if err != nil {
-		fmt.Println("Server is unable to create the ScPrime node.")
-		fmt.Println(err)
+		log.Error("Server is unable to create the ScPrime node", logger.Err(err))
+		sd.status("Unable to create the ScPrime node: %v", err)
+		return
+	}
+	if !proceed {
 		return
 	}
-	// Print a 'startup complete' message.
-	startupTime := time.Since(loadStart)
-	fmt.Printf("Finished full startup in %.3f seconds\n", startupTime.Seconds())
-	return
+	var errChanCS <-chan error
+	started := false
+	for {
+		errChan, err := loadModules(srv, node, params, sd, log, stop)
+		if errChan != nil {
+			errChanCS = errChan
+		}
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		consensusFailed := false
+		if err == nil {
+			sd.status("Web wallet is ready")
+			if !started {
+				// Log a 'startup complete' message.
+				log.Info("Finished full startup", logger.F("duration", time.Since(loadStart)))
+				close(ready)
+				started = true
+			} else {
+				log.Info("Finished loading modules")
+			}
+			err = waitForConsensusError(errChanCS, stop)
+			if err == nil {
+				return
+			}
+			consensusFailed = true
+			errChanCS = nil
+			log.Error("Consensus set failed", logger.Err(err))
+			srv.SetModuleState(server.ModuleConsensusSet, server.ModuleFailed, err)
+		} else {
+			log.Error("Server is unable to create the ScPrime node", logger.Err(err))
+		}
+		sd.status("Unable to create the ScPrime node, waiting for a retry in the GUI: %v", err)
+		var action server.StartupAction
+		select {
+		case action = <-srv.StartupActions():
+		case <-stop:
+			return
+		}
+		log.Info("Retrying to load the modules", logger.F("action", action))
+		reset := action == server.StartupResetConsensus
+		if reset && !srv.CanResetConsensus() {
+			// Only a failed consensus set is deleted.
+			log.Warn("Not resetting the consensus set, it did not fail to load")
+			reset = false
+		}
+		if consensusFailed || reset {
+			errChanCS = nil
+			err = unloadConsensus(srv, node, params, reset, log.With(logger.F("module", "consensus")))
+			if err != nil {
+				log.Error("Unable to reset the consensus set", logger.Err(err))
+			}
+		} else {
+			srv.ClearModuleFailures()
+		}
+	}
+}
+
+// waitForConsensusError returns the error that the consensus set's
+// asynchronous startup failed with. It returns nil once stop is closed.
+func waitForConsensusError(errChan <-chan error, stop <-chan struct{}) error {
+	select {
+	case err := <-errChan:
+		// The consensus set also fails when it is closed during shutdown.
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+		if err != nil {
+			return err
+		}
+	case <-stop:
+		return nil
+	}
+	<-stop
+	return nil
 }
 
-func launchGui(params *node.NodeParams) bool {
+// openRunningInstance opens the GUI of the web wallet that is already running
+// with the data directory. A headless daemon has no GUI to open, so it fails
+// with the lock error instead.
+func openRunningInstance(config Config, lockErr error) error {
+	if config.Headless {
+		return lockErr
+	}
+	log := logger.New(os.Stdout, config.LogLevel)
+	log.Info("The web wallet is already running, opening its GUI", logger.F("dir", config.NodeParams.Dir))
+	url, err := runningURL(config.NodeParams.Dir)
+	if err != nil {
+		return fmt.Errorf("unable to find the GUI of the running web wallet: %w", err)
+	}
+	if !launchGui(&config.NodeParams, url, log) {
+		return errors.New("unable to open the GUI of the running web wallet")
+	}
+	return nil
+}
+
+func launchGui(params *node.NodeParams, url string, log logger.Logger) bool {
+	if url == "" {
+		log.Warn("The GUI is not served at a URL a browser can open")
+		return false
+	}
 	dir, err := filepath.Abs(params.Dir)
 	if err != nil {
-		fmt.Printf("unable to launch GUI: %v\n", err)
+		log.Error("Unable to launch GUI", logger.Err(err))
 		return false
 	}
 	browser, _ := browserconfig.Browser(dir)
-	return launcher.Launch(browser)
+	return launcher.Launch(browser, url)
 }
 
 // StartDaemon uses the config parameters to initialize modules and start the web wallet.
-func StartDaemon(nodeParams *node.NodeParams) (err error) {
+func StartDaemon(config Config) (err error) {
+	nodeParams := &config.NodeParams
 	// Record startup time
 	loadStart := time.Now()
 
 	// listen for kill signals
 	sigChan := installKillSignalHandler()
 
-	// Print the Version and GitRevision
-	printVersionAndRevision()
+	// Make sure that this is the only web wallet using the data directory.
+	// When another one is running, open its GUI instead of starting a
+	// second node.
+	lock, err := lockDataDir(nodeParams.Dir)
+	if errors.Is(err, ErrAlreadyRunning) {
+		return openRunningInstance(config, err)
+	} else if err != nil {
+		return fmt.Errorf("unable to lock the data directory: %w", err)
+	}
+	defer lock.unlock()
+
+	// Log to stdout and the log file and hand the logger to the modules.
+	log, logFile := newLogger(config)
+	defer logFile.Close()
+	bootstrapper.SetLogger(log.With(logger.F("module", "bootstrapper")))
+	bootstrapper.SetMirrors(config.BootstrapMirrors)
+	browserconfig.SetLogger(log.With(logger.F("module", "browserconfig")))
+	consensusbuilder.SetLogger(log.With(logger.F("module", "consensusbuilder")))
+
+	// Log the Version and GitRevision
+	printVersionAndRevision(log)
 
 	// Install a signal handler that will catch exceptions thrown by mmap'd
 	// files.
-	installMmapSignalHandler()
+	installMmapSignalHandler(log)
 
-	// Print a startup message.
-	fmt.Println("Loading ScPrime Web Wallet...")
+	// Log a startup message.
+	log.Info("Loading ScPrime Web Wallet")
 
-	// Start Server
-	server.StartHTTPServer()
+	// Notify systemd of the startup progress when it started the daemon.
+	sd := newSDNotifier(log.With(logger.F("module", "systemd")))
+	stopWatchdog := make(chan struct{})
+	defer close(stopWatchdog)
+	go sd.threadedWatchdog(stopWatchdog)
 
-	// Start a node
-	node := &node.Node{}
-	if server.IsRunning() {
-		go startNode(node, nodeParams, loadStart)
+	// stopNode is closed before the node is closed so that its failures
+	// during shutdown are not reported.
+	stopNode := make(chan struct{})
+
+	// Use the configured browser instead of asking for one. A headless daemon
+	// does not launch a browser, so it does not need one.
+	if config.Headless {
+		browserconfig.Skip()
+	} else if config.Browser != "" {
+		browserconfig.Override(config.Browser)
 	}
 
-	if server.IsRunning() {
-		// Block until node is started or 500 milliseconds has passed.
-		for i := 0; i < 100; i++ {
-			if node.TransactionPool == nil {
-				time.Sleep(5 * time.Millisecond)
-			}
+	// Start Server
+	serverOpts := []server.Option{
+		server.WithDataDir(nodeParams.Dir),
+		server.WithLogger(log.With(logger.F("module", "server"))),
+	}
+	serverOpts = append(serverOpts, config.ServerOptions...)
+	if config.Headless {
+		serverOpts = append(serverOpts, server.WithHeadless())
+	}
+	listener, err := activatedListener(log)
+	if err != nil {
+		return err
+	}
+	if listener != nil {
+		log.Info("Serving on the socket passed by systemd", logger.F("address", listener.Addr()))
+		serverOpts = append(serverOpts, server.WithListener(listener))
+	}
+	srv := server.New(serverOpts...)
+	err = srv.Start()
+	if err != nil {
+		log.Error("Unable to start server", logger.Err(err))
+		return fmt.Errorf("unable to start the server: %w", err)
+	}
+	if url := srv.URL(); url != "" {
+		log.Info("Serving the GUI", logger.F("url", url))
+		if err := lock.setURL(url); err != nil {
+			log.Warn("Unable to record the URL of the GUI", logger.Err(err))
 		}
 	}
 
-	// Launch the GUI
-	launchGui(nodeParams)
+	// Start a node. The GUI is needed to finish loading the node, so the
+	// daemon is ready as soon as it is served.
+	node := &node.Node{}
+	nodeReady := make(chan struct{})
+	nodeDone := make(chan struct{})
+	sd.ready("Loading modules")
+	go func() {
+		defer close(nodeDone)
+		startNode(srv, node, nodeParams, sd, log, loadStart, nodeReady, stopNode)
+	}()
+	// Block until node is started or 500 milliseconds has passed.
+	select {
+	case <-nodeReady:
+	case <-nodeDone:
+	case <-time.After(500 * time.Millisecond):
+	}
 
-	if !server.IsRunning() {
-		return nil
+	// Launch the GUI
+	if config.Headless {
+		log.Info("Running headless, open the GUI in a browser to use the web wallet", logger.F("url", srv.URL()))
+	} else {
+		launchGui(nodeParams, srv.URL(), log)
 	}
 
 	select {
-	case <-server.Wait():
-		fmt.Println("Server was stopped, quitting...")
+	case <-srv.ShutdownRequested():
+		log.Info("GUI was closed, quitting")
+	case <-srv.Wait():
+		log.Info("Server was stopped, quitting")
 	case <-sigChan:
-		fmt.Println("\rCaught stop signal, quitting...")
+		log.Info("Caught stop signal, quitting")
 	}
 
+	// A second stop signal skips the graceful shutdown.
+	go func() {
+		<-sigChan
+		log.Error("Caught a second stop signal, quitting without shutting down")
+		os.Exit(1)
+	}()
+
 	// Close
-	server.CloseAllWallets()
-	if node != nil {
-		closeNode(node, nodeParams)
+	sd.stopping()
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err = shutdown(ctx, shutdownSteps(srv, node, stopNode, nodeDone, log), log)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrShutdown, err)
 	}
 	return nil
 }
+
+// shutdownSteps returns the steps that shut the web wallet down. The server
+// stops first and waits for the requests and wallet operations in flight, so
+// that no wallet is used while it is being closed. Loading the node is stopped
+// before the wallets and then the node's modules are closed, unless loading
+// did not stop in time.
+func shutdownSteps(srv *server.Server, node *node.Node, stopNode chan struct{}, nodeDone <-chan struct{}, log logger.Logger) []shutdownStep {
+	return []shutdownStep{
+		{
+			name:    "server",
+			timeout: serverShutdownTimeout,
+			run:     srv.Shutdown,
+		},
+		{
+			name:    "node startup",
+			timeout: stopLoadingTimeout,
+			run: func(ctx context.Context) error {
+				close(stopNode)
+				stopLoading()
+				select {
+				case <-nodeDone:
+					return nil
+				case <-ctx.Done():
+					return fmt.Errorf("node did not stop loading: %w", ctx.Err())
+				}
+			},
+		},
+		{
+			name:    "wallets",
+			timeout: closeWalletsTimeout,
+			run: func(ctx context.Context) error {
+				return srv.CloseAllWallets()
+			},
+		},
+		{
+			name:    "node",
+			timeout: closeNodeTimeout,
+			run: func(ctx context.Context) error {
+				// The modules that are still being loaded cannot be closed
+				// safely, so they are left to the exit of the process.
+				select {
+				case <-nodeDone:
+				default:
+					return errors.New("node is still loading, its modules were not closed")
+				}
+				return closeNode(node, log)
+			},
+		},
+	}
+}
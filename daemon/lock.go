@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/starius/flock"
+)
+
+// lockFileName is the name of the file in the data directory that is locked
+// while a web wallet uses the directory.
+const lockFileName = "scp-webwallet.lock"
+
+// urlFileName is the name of the file in the data directory that holds the
+// URL of the GUI of the web wallet that holds the lock. It is separate from
+// the lock file because Windows does not allow other processes to read a
+// locked file.
+const urlFileName = "scp-webwallet.url"
+
+// ErrAlreadyRunning is returned when another web wallet is already using the
+// data directory.
+var ErrAlreadyRunning = errors.New("another web wallet is already running with this data directory")
+
+// dirLock is the lock on the data directory.
+type dirLock struct {
+	dir  string
+	file *os.File
+}
+
+// lockDataDir locks the data directory so that only one web wallet at a time
+// opens its consensus set and wallets. It returns ErrAlreadyRunning when
+// another process holds the lock, and any other error when the lock cannot be
+// taken. The operating system releases the lock when
+// the process exits, so a crashed web wallet does not leave it behind.
+func lockDataDir(dir string) (*dirLock, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, lockFileName), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := tryLock(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", f.Name(), err)
+	} else if !locked {
+		f.Close()
+		return nil, ErrAlreadyRunning
+	}
+	// Remove the URL left behind by a web wallet that crashed, so that a
+	// second launch does not open it before the URL has been recorded.
+	os.Remove(filepath.Join(dir, urlFileName))
+	// Record the process ID to help finding the running web wallet.
+	err = f.Truncate(0)
+	if err == nil {
+		_, err = f.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+	}
+	if err != nil {
+		flock.UnlockFile(f)
+		f.Close()
+		return nil, err
+	}
+	return &dirLock{dir: dir, file: f}, nil
+}
+
+// setURL records the URL of the GUI so that a second launch can open it.
+func (l *dirLock) setURL(url string) error {
+	return ioutil.WriteFile(filepath.Join(l.dir, urlFileName), []byte(url+"\n"), 0600)
+}
+
+// runningURL returns the URL of the GUI of the web wallet that holds the lock
+// on the data directory.
+func runningURL(dir string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, urlFileName))
+	if err != nil {
+		return "", err
+	}
+	url := strings.TrimSpace(string(b))
+	if url == "" {
+		return "", errors.New("the running web wallet has not recorded its URL")
+	}
+	return url, nil
+}
+
+// unlock releases the lock on the data directory. The lock file is left in
+// place, since removing it could let another process lock a file that is no
+// longer in the directory.
+func (l *dirLock) unlock() error {
+	os.Remove(filepath.Join(l.dir, urlFileName))
+	err := flock.UnlockFile(l.file)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
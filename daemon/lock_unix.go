@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package daemon
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"github.com/starius/flock"
+)
+
+// tryLock locks the file without waiting. It returns false and no error when
+// another process holds the lock.
+func tryLock(f *os.File) (bool, error) {
+	err := flock.LockFile(f)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,22 @@
+//go:build windows
+// +build windows
+
+package daemon
+
+import (
+	"errors"
+	"os"
+
+	"github.com/starius/flock"
+	"golang.org/x/sys/windows"
+)
+
+// tryLock locks the file without waiting. It returns false and no error when
+// another process holds the lock.
+func tryLock(f *os.File) (bool, error) {
+	err := flock.LockFile(f)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return false, nil
+	}
+	return err == nil, err
+}
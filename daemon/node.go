@@ -2,9 +2,13 @@ package daemon
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
 	"time"
 
+	"gitlab.com/NebulousLabs/errors"
+
 	"gitlab.com/scpcorp/ScPrime/modules"
 	"gitlab.com/scpcorp/ScPrime/modules/consensus"
 	"gitlab.com/scpcorp/ScPrime/modules/gateway"
@@ -12,107 +16,243 @@ import (
 	"gitlab.com/scpcorp/ScPrime/modules/wallet"
 	"gitlab.com/scpcorp/ScPrime/node"
 
+	"gitlab.com/scpcorp/webwallet/logger"
 	"gitlab.com/scpcorp/webwallet/modules/bootstrapper"
 	"gitlab.com/scpcorp/webwallet/modules/browserconfig"
 	"gitlab.com/scpcorp/webwallet/modules/consensesbuilder"
 	"gitlab.com/scpcorp/webwallet/server"
 )
 
-func loadNode(node *node.Node, params *node.NodeParams) error {
-	fmt.Println("Loading modules:")
+// newConsensusSet creates the consensus set. Tests replace it to make the
+// consensus set fail to load.
+var newConsensusSet = consensus.NewCustomConsensusSet
+
+// prepareNode configures the browser, bootstraps the consensus set if
+// necessary and attaches the node to the server. It returns false when the
+// daemon has to be restarted before the node can be loaded.
+func prepareNode(srv *server.Server, node *node.Node, params *node.NodeParams, sd *sdNotifier, log logger.Logger) (bool, error) {
 	// Make sure the path is an absolute one.
 	dir, err := filepath.Abs(params.Dir)
 	if err != nil {
-		return err
+		return false, err
 	}
 	node.Dir = dir
 	// Configure Browser
-	needsShutdown, err := initializeBrowser(params)
+	sd.status("Initializing browser")
+	srv.StartPhase(server.PhaseBrowser, nil)
+	needsShutdown, err := initializeBrowser(params, log.With(logger.F("module", "browserconfig")))
+	srv.EndPhase(server.PhaseBrowser, err)
 	if err != nil {
-		return err
+		return false, err
 	} else if needsShutdown {
-		return nil
+		return false, nil
 	}
 	// Bootstrap Consensus Set if necessary
-	bootstrapConsensusSet(params)
+	done := make(chan struct{})
+	go sd.threadedProgress("Bootstrapping consensus", bootstrapper.Progress, done)
+	srv.StartPhase(server.PhaseBootstrapper, bootstrapper.Progress)
+	// A failed bootstrap is shown in the GUI, and the consensus set is built
+	// from peers instead.
+	bootstrapErr := bootstrapConsensusSet(params, log.With(logger.F("module", "bootstrapper")))
+	srv.EndPhase(server.PhaseBootstrapper, bootstrapErr)
+	close(done)
 	// Attach Node To Server
-	server.AttachNode(node, params)
+	srv.AttachNode(node, params)
+	return true, nil
+}
+
+// loadModules loads the node modules that are not loaded yet. It returns the
+// channel that reports the result of the consensus set's asynchronous
+// startup when the consensus set was loaded. No more modules are loaded once
+// stop is closed.
+func loadModules(srv *server.Server, node *node.Node, params *node.NodeParams, sd *sdNotifier, log logger.Logger, stop <-chan struct{}) (<-chan error, error) {
+	log.Info("Loading modules")
 	// Load Gateway.
-	err = loadGateway(params, node)
-	if err != nil {
-		return err
+	if node.Gateway == nil && !stopped(stop) {
+		sd.status("Loading gateway")
+		srv.SetModuleState(server.ModuleGateway, server.ModuleLoading, nil)
+		srv.StartPhase(server.PhaseGateway, nil)
+		err := loadGateway(srv, params, node, log.With(logger.F("module", "gateway")))
+		srv.EndPhase(server.PhaseGateway, err)
+		if err != nil {
+			srv.SetModuleState(server.ModuleGateway, server.ModuleFailed, err)
+			return nil, err
+		}
+		srv.SetModuleState(server.ModuleGateway, server.ModuleLoaded, nil)
 	}
 	// Load Consensus Set
-	err = loadConsensusSet(params, node)
-	if err != nil {
-		return err
+	var errChanCS <-chan error
+	if node.ConsensusSet == nil && !stopped(stop) {
+		sd.status("Loading consensus set")
+		srv.SetModuleState(server.ModuleConsensusSet, server.ModuleLoading, nil)
+		srv.StartPhase(server.PhaseConsensusSet, nil)
+		var err error
+		errChanCS, err = loadConsensusSet(srv, params, node, log.With(logger.F("module", "consensus")))
+		srv.EndPhase(server.PhaseConsensusSet, err)
+		if err != nil {
+			srv.SetModuleState(server.ModuleConsensusSet, server.ModuleFailed, err)
+			return nil, err
+		}
+		// Build Consensus Set if necessary
+		done := make(chan struct{})
+		go sd.threadedProgress("Building consensus set", consensusbuilder.Progress, done)
+		srv.StartPhase(server.PhaseConsensusBuilder, consensusbuilder.Progress)
+		buildConsensusSet(params, log.With(logger.F("module", "consensusbuilder")))
+		srv.EndPhase(server.PhaseConsensusBuilder, nil)
+		close(done)
+		srv.SetModuleState(server.ModuleConsensusSet, server.ModuleLoaded, nil)
 	}
-	// Build Consensus Set if necessary
-	buildConsensusSet(params)
 	// Load Transaction Pool
-	err = loadTransactionPool(params, node)
+	if node.TransactionPool == nil && !stopped(stop) {
+		sd.status("Loading transaction pool")
+		srv.SetModuleState(server.ModuleTransactionPool, server.ModuleLoading, nil)
+		srv.StartPhase(server.PhaseTransactionPool, nil)
+		err := loadTransactionPool(srv, params, node, log.With(logger.F("module", "transactionpool")))
+		srv.EndPhase(server.PhaseTransactionPool, err)
+		if err != nil {
+			srv.SetModuleState(server.ModuleTransactionPool, server.ModuleFailed, err)
+			return errChanCS, err
+		}
+		srv.SetModuleState(server.ModuleTransactionPool, server.ModuleLoaded, nil)
+	}
+	return errChanCS, nil
+}
+
+// unloadConsensus closes the transaction pool and the consensus set so that
+// they can be loaded again. The node is detached from the server and the
+// wallets are closed first because they depend on both, and the node is
+// attached again once they have been closed. When reset is true the consensus
+// set's database is deleted.
+func unloadConsensus(srv *server.Server, node *node.Node, params *node.NodeParams, reset bool, log logger.Logger) error {
+	err := srv.ReleaseNode()
 	if err != nil {
-		return err
+		log.Warn("Unable to close the wallets", logger.Err(err))
+	}
+	defer func() {
+		srv.ClearModuleFailures()
+		srv.AttachNode(node, params)
+	}()
+	if node.TransactionPool != nil {
+		err = node.TransactionPool.Close()
+		node.TransactionPool = nil
+		if err != nil {
+			log.Warn("Unable to close the transaction pool", logger.Err(err))
+		}
+		srv.SetModuleState(server.ModuleTransactionPool, server.ModuleNotLoaded, nil)
+	}
+	if node.ConsensusSet != nil {
+		err = node.ConsensusSet.Close()
+		node.ConsensusSet = nil
+		if err != nil {
+			log.Warn("Unable to close the consensus set", logger.Err(err))
+		}
+		srv.SetModuleState(server.ModuleConsensusSet, server.ModuleNotLoaded, nil)
+	}
+	if !reset {
+		return nil
+	}
+	consensusDir := filepath.Join(node.Dir, modules.ConsensusDir)
+	log.Warn("Resetting consensus set", logger.F("dir", consensusDir))
+	return os.RemoveAll(consensusDir)
+}
+
+// stopped returns whether stop is closed.
+func stopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
 	}
-	return nil
 }
 
-func closeNode(node *node.Node, params *node.NodeParams) error {
-	fmt.Println("Closing modules:")
-	params.CreateWallet = false
-	params.CreateTransactionPool = false
+// stopLoading stops the bootstrapper, the consensus set builder and the
+// browser configuration, so that loading the node, which was told to stop
+// loading modules, finishes.
+func stopLoading() {
 	consensusbuilder.Close()
-	params.CreateConsensusSet = false
-	params.CreateGateway = false
-	err := node.Close()
 	bootstrapper.Close()
 	browserconfig.Close()
+}
+
+// closeNode closes the node's modules in dependency order: the wallet and the
+// transaction pool depend on the consensus set, which depends on the gateway.
+// Every module is closed even when closing an earlier one fails.
+func closeNode(node *node.Node, log logger.Logger) error {
+	log.Info("Closing modules")
+	nodeModules := []struct {
+		name   string
+		module io.Closer
+	}{
+		{"wallet", node.Wallet},
+		{"transaction pool", node.TransactionPool},
+		{"consensus set", node.ConsensusSet},
+		{"gateway", node.Gateway},
+	}
+	var err error
+	for _, m := range nodeModules {
+		if m.module == nil {
+			continue
+		}
+		log.Info("Closing the " + m.name)
+		if closeErr := m.module.Close(); closeErr != nil {
+			log.Error("Unable to close the "+m.name, logger.Err(closeErr))
+			err = errors.Compose(err, fmt.Errorf("unable to close the %s: %w", m.name, closeErr))
+		}
+	}
 	return err
 }
 
-func initializeBrowser(params *node.NodeParams) (bool, error) {
+func initializeBrowser(params *node.NodeParams, log logger.Logger) (bool, error) {
 	loadStart := time.Now()
-	fmt.Printf("Initializing browser...")
+	log.Info("Initializing browser")
 	time.Sleep(1 * time.Millisecond)
 	browserconfig.Start(params.Dir)
-	loadTime := time.Since(loadStart).Seconds()
+	loadTime := logger.F("duration", time.Since(loadStart))
+	if browserconfig.Skipped() {
+		log.Info("Browser initialization skipped", loadTime)
+		return false, nil
+	}
 	if browserconfig.Status() == browserconfig.Closed {
-		fmt.Println(" closed after", loadTime, "seconds.")
+		log.Info("Browser initialization closed", loadTime)
 		return true, nil
 	}
 	if browserconfig.Status() == browserconfig.Failed {
-		fmt.Println(" failed after", loadTime, "seconds.")
+		log.Error("Browser initialization failed", loadTime)
 		return true, nil
 	}
 	browser, err := browserconfig.Browser(params.Dir)
 	if err != nil {
-		fmt.Println(" failed after", loadTime, "seconds.")
+		log.Error("Browser initialization failed", loadTime, logger.Err(err))
 		return true, err
 	}
 	if browserconfig.Status() == browserconfig.Initialized {
-		fmt.Printf(" browser initialized to %s in %v seconds.\n", browser, loadTime)
+		log.Info("Browser initialized", logger.F("browser", browser), loadTime)
 		return true, nil
 	}
-	fmt.Printf(" browser set to %s in %v seconds.\n", browser, loadTime)
+	log.Info("Browser set", logger.F("browser", browser), loadTime)
 	return false, nil
 }
 
-func bootstrapConsensusSet(params *node.NodeParams) {
+func bootstrapConsensusSet(params *node.NodeParams, log logger.Logger) error {
 	loadStart := time.Now()
-	fmt.Printf("Bootstrapping consensus...")
+	log.Info("Bootstrapping consensus")
 	time.Sleep(1 * time.Millisecond)
-	bootstrapper.Start(params.Dir)
-	loadTime := time.Since(loadStart).Seconds()
-	if bootstrapper.Progress() == bootstrapper.Skipped {
-		fmt.Println(" skipped after", loadTime, "seconds.")
+	err := bootstrapper.Start(params.Dir)
+	loadTime := logger.F("duration", time.Since(loadStart))
+	if err != nil {
+		log.Error("Bootstrapping consensus failed", loadTime, logger.Err(err))
+	} else if bootstrapper.Progress() == bootstrapper.Skipped {
+		log.Info("Bootstrapping consensus skipped", loadTime)
 	} else if bootstrapper.Progress() == bootstrapper.Closed {
-		fmt.Println(" closed after", loadTime, "seconds.")
+		log.Info("Bootstrapping consensus closed", loadTime)
 	} else {
-		fmt.Println(" done in", loadTime, "seconds.")
+		log.Info("Bootstrapping consensus done", loadTime)
 	}
+	return err
 }
 
-func loadGateway(params *node.NodeParams, node *node.Node) error {
+func loadGateway(srv *server.Server, params *node.NodeParams, node *node.Node, log logger.Logger) error {
 	loadStart := time.Now()
 	if !params.CreateGateway {
 		return nil
@@ -124,63 +264,72 @@ func loadGateway(params *node.NodeParams, node *node.Node) error {
 	if gatewayDeps == nil {
 		gatewayDeps = modules.ProdDependencies
 	}
-	fmt.Printf("Loading gateway...")
+	log.Info("Loading gateway", logger.F("address", params.RPCAddress))
 	dir := node.Dir
 	g, err := gateway.NewCustomGateway(params.RPCAddress, params.Bootstrap, filepath.Join(dir, modules.GatewayDir), gatewayDeps)
 	if err != nil {
 		return err
 	}
 	if g != nil {
-		fmt.Println(" done in", time.Since(loadStart).Seconds(), "seconds.")
+		log.Info("Gateway loaded", logger.F("duration", time.Since(loadStart)))
 	}
-	node.Gateway = g
+	srv.UpdateNode(func() { node.Gateway = g })
 	return nil
 }
 
-func loadConsensusSet(params *node.NodeParams, node *node.Node) error {
+// loadConsensusSet loads the consensus set. It returns the channel that
+// reports the result of the consensus set's asynchronous startup, which keeps
+// running after it has been loaded.
+func loadConsensusSet(srv *server.Server, params *node.NodeParams, node *node.Node, log logger.Logger) (<-chan error, error) {
 	loadStart := time.Now()
-	c := make(chan error, 1)
-	defer close(c)
 	if !params.CreateConsensusSet {
-		return nil
+		return nil, nil
 	}
-	fmt.Printf("Loading consensus set...")
+	log.Info("Loading consensus set")
 	consensusSetDeps := params.ConsensusSetDeps
 	if consensusSetDeps == nil {
 		consensusSetDeps = modules.ProdDependencies
 	}
 	g := node.Gateway
 	dir := node.Dir
-	cs, errChanCS := consensus.NewCustomConsensusSet(g, params.Bootstrap, filepath.Join(dir, modules.ConsensusDir), consensusSetDeps)
+	cs, errChanCS := newConsensusSet(g, params.Bootstrap, filepath.Join(dir, modules.ConsensusDir), consensusSetDeps)
 	if err := modules.PeekErr(errChanCS); err != nil {
-		return err
+		// The consensus set is returned with the error when its asynchronous
+		// startup has already failed. It is closed so that its database can
+		// be opened again when the user retries.
+		if cs != nil {
+			if closeErr := cs.Close(); closeErr != nil {
+				log.Warn("Unable to close the consensus set", logger.Err(closeErr))
+			}
+		}
+		return nil, err
 	}
 	if cs != nil {
-		fmt.Println(" done in", time.Since(loadStart).Seconds(), "seconds.")
+		log.Info("Consensus set loaded", logger.F("duration", time.Since(loadStart)))
 	}
-	node.ConsensusSet = cs
-	return nil
+	srv.UpdateNode(func() { node.ConsensusSet = cs })
+	return errChanCS, nil
 }
 
-func buildConsensusSet(params *node.NodeParams) {
+func buildConsensusSet(params *node.NodeParams, log logger.Logger) {
 	loadStart := time.Now()
-	fmt.Printf("Building consensus set...")
+	log.Info("Building consensus set")
 	time.Sleep(1 * time.Millisecond)
 	consensusbuilder.Start(params.Dir)
-	loadTime := time.Since(loadStart).Seconds()
+	loadTime := logger.F("duration", time.Since(loadStart))
 	if consensusbuilder.Progress() == consensusbuilder.Closed {
-		fmt.Println(" closed after", loadTime, "seconds.")
+		log.Info("Building consensus set closed", loadTime)
 	} else {
-		fmt.Println(" done in", loadTime, "seconds.")
+		log.Info("Building consensus set done", loadTime)
 	}
 }
 
-func loadTransactionPool(params *node.NodeParams, node *node.Node) error {
+func loadTransactionPool(srv *server.Server, params *node.NodeParams, node *node.Node, log logger.Logger) error {
 	loadStart := time.Now()
 	if !params.CreateTransactionPool {
 		return nil
 	}
-	fmt.Printf("Loading transaction pool...")
+	log.Info("Loading transaction pool")
 	tpoolDeps := params.TPoolDeps
 	if tpoolDeps == nil {
 		tpoolDeps = modules.ProdDependencies
@@ -193,14 +342,14 @@ func loadTransactionPool(params *node.NodeParams, node *node.Node) error {
 		return err
 	}
 	if tp != nil {
-		fmt.Println(" done in", time.Since(loadStart).Seconds(), "seconds.")
+		log.Info("Transaction pool loaded", logger.F("duration", time.Since(loadStart)))
 	}
-	node.TransactionPool = tp
+	srv.UpdateNode(func() { node.TransactionPool = tp })
 	return nil
 }
 
 // LoadWallet loads the wallet module
-func LoadWallet(params *node.NodeParams, node *node.Node, walletDirName string) error {
+func LoadWallet(params *node.NodeParams, node *node.Node, walletDirName string, log logger.Logger) error {
 	loadStart := time.Now()
 	if !params.CreateWallet {
 		return nil
@@ -209,7 +358,8 @@ func LoadWallet(params *node.NodeParams, node *node.Node, walletDirName string)
 	if walletDeps == nil {
 		walletDeps = modules.ProdDependencies
 	}
-	fmt.Printf("Loading wallet...")
+	log = log.With(logger.F("module", "wallet"), logger.F("wallet", walletDirName))
+	log.Info("Loading wallet")
 	cs := node.ConsensusSet
 	tp := node.TransactionPool
 	dir := node.Dir
@@ -218,7 +368,7 @@ func LoadWallet(params *node.NodeParams, node *node.Node, walletDirName string)
 		return err
 	}
 	if w != nil {
-		fmt.Println(" done in", time.Since(loadStart).Seconds(), "seconds.")
+		log.Info("Wallet loaded", logger.F("duration", time.Since(loadStart)))
 	}
 	node.Wallet = w
 	return nil
@@ -0,0 +1,68 @@
+package daemon
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"gitlab.com/scpcorp/ScPrime/modules"
+	"gitlab.com/scpcorp/ScPrime/modules/consensus"
+	"gitlab.com/scpcorp/ScPrime/node"
+
+	"gitlab.com/scpcorp/webwallet/logger"
+	"gitlab.com/scpcorp/webwallet/server"
+)
+
+// TestLoadConsensusSetRetry tests that a consensus set whose asynchronous
+// startup failed is closed, so that it can be loaded again from the same
+// database.
+func TestLoadConsensusSetRetry(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	dir := t.TempDir()
+	log := logger.New(ioutil.Discard, logger.LevelError)
+	srv := server.New(server.WithDataDir(dir), server.WithLogger(log))
+	params := &node.NodeParams{
+		CreateGateway:      true,
+		CreateConsensusSet: true,
+		Dir:                dir,
+	}
+	n := &node.Node{Dir: dir}
+	if err := loadGateway(srv, params, n, log); err != nil {
+		t.Fatal(err)
+	}
+	defer n.Gateway.Close()
+
+	// Fail the asynchronous startup of the first consensus set.
+	errStartup := errors.New("startup failed")
+	prev := newConsensusSet
+	newConsensusSet = func(g modules.Gateway, bootstrap bool, persistDir string, deps modules.Dependencies) (*consensus.ConsensusSet, <-chan error) {
+		cs, _ := prev(g, bootstrap, persistDir, deps)
+		errChan := make(chan error, 1)
+		errChan <- errStartup
+		return cs, errChan
+	}
+	defer func() {
+		newConsensusSet = prev
+	}()
+	if _, err := loadConsensusSet(srv, params, n, log); !errors.Is(err, errStartup) {
+		t.Fatalf("expected %v, got %v", errStartup, err)
+	}
+	if n.ConsensusSet != nil {
+		t.Fatal("the failed consensus set was attached to the node")
+	}
+
+	// Retry. The database of the failed consensus set must have been closed
+	// for it to be opened again.
+	newConsensusSet = prev
+	if _, err := loadConsensusSet(srv, params, n, log); err != nil {
+		t.Fatal(err)
+	}
+	if n.ConsensusSet == nil {
+		t.Fatal("the consensus set was not attached to the node")
+	}
+	if err := n.ConsensusSet.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
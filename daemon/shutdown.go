@@ -0,0 +1,68 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"gitlab.com/scpcorp/webwallet/logger"
+)
+
+// Shutdown deadlines. Every step has its own deadline so that a step that
+// hangs does not keep the later steps from running, and the whole shutdown is
+// bounded by shutdownTimeout.
+const (
+	shutdownTimeout       = 2 * time.Minute
+	serverShutdownTimeout = 30 * time.Second
+	stopLoadingTimeout    = 15 * time.Second
+	closeWalletsTimeout   = 30 * time.Second
+	closeNodeTimeout      = 45 * time.Second
+)
+
+// ErrShutdown is returned by StartDaemon when the web wallet did not shut down
+// cleanly.
+var ErrShutdown = errors.New("unclean shutdown")
+
+// shutdownStep is a step of the shutdown.
+type shutdownStep struct {
+	name    string
+	timeout time.Duration
+	run     func(ctx context.Context) error
+}
+
+// shutdown runs the steps in order. Every step runs even when an earlier one
+// failed or timed out, and the errors of all of them are returned.
+func shutdown(ctx context.Context, steps []shutdownStep, log logger.Logger) error {
+	var err error
+	for _, step := range steps {
+		err = errors.Compose(err, runShutdownStep(ctx, step, log))
+	}
+	return err
+}
+
+// runShutdownStep runs the step until it returns or its deadline expires. A
+// step that misses its deadline is left running in the background.
+func runShutdownStep(ctx context.Context, step shutdownStep, log logger.Logger) error {
+	ctx, cancel := context.WithTimeout(ctx, step.timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- step.run(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	duration := logger.F("duration", time.Since(start))
+	if err != nil {
+		log.Error("Shutdown step failed", logger.F("step", step.name), duration, logger.Err(err))
+		return fmt.Errorf("%s: %w", step.name, err)
+	}
+	log.Info("Shutdown step finished", logger.F("step", step.name), duration)
+	return nil
+}
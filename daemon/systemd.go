@@ -0,0 +1,174 @@
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"gitlab.com/scpcorp/webwallet/logger"
+)
+
+// Environment variables that systemd passes to the services it starts. See
+// sd_notify(3) and sd_listen_fds(3).
+const (
+	envNotifySocket = "NOTIFY_SOCKET"
+	envWatchdogUsec = "WATCHDOG_USEC"
+	envWatchdogPID  = "WATCHDOG_PID"
+	envListenPID    = "LISTEN_PID"
+	envListenFDs    = "LISTEN_FDS"
+	envListenNames  = "LISTEN_FDNAMES"
+)
+
+// listenFDsStart is the first file descriptor systemd passes to a socket
+// activated service.
+const listenFDsStart = 3
+
+// progressInterval is how often the progress of a long running startup phase
+// is sent to systemd.
+const progressInterval = time.Second
+
+// sdNotifier sends state notifications to systemd. It does nothing when the
+// daemon was not started by systemd as a Type=notify service, so it is always
+// safe to use.
+type sdNotifier struct {
+	socket   string
+	watchdog time.Duration
+	log      logger.Logger
+}
+
+// newSDNotifier returns a notifier for the socket systemd passed in the
+// environment. The variables are removed so that child processes do not
+// inherit them.
+func newSDNotifier(log logger.Logger) *sdNotifier {
+	n := &sdNotifier{
+		socket: os.Getenv(envNotifySocket),
+		log:    log,
+	}
+	usec, err := strconv.ParseInt(os.Getenv(envWatchdogUsec), 10, 64)
+	pid := os.Getenv(envWatchdogPID)
+	if err == nil && usec > 0 && (pid == "" || pid == strconv.Itoa(os.Getpid())) {
+		n.watchdog = time.Duration(usec) * time.Microsecond
+	}
+	os.Unsetenv(envNotifySocket)
+	os.Unsetenv(envWatchdogUsec)
+	os.Unsetenv(envWatchdogPID)
+	return n
+}
+
+// notify sends the state to systemd.
+func (n *sdNotifier) notify(state string) error {
+	if n.socket == "" {
+		return nil
+	}
+	// Go maps a leading @ to the abstract socket namespace.
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: n.socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// status sends a free form status message that systemctl status shows.
+func (n *sdNotifier) status(format string, args ...interface{}) {
+	err := n.notify("STATUS=" + fmt.Sprintf(format, args...))
+	if err != nil {
+		n.log.Warn("Unable to notify systemd", logger.Err(err))
+	}
+}
+
+// ready tells systemd that the daemon has finished starting up.
+func (n *sdNotifier) ready(status string) {
+	err := n.notify("READY=1\nSTATUS=" + status)
+	if err != nil {
+		n.log.Warn("Unable to notify systemd", logger.Err(err))
+	}
+}
+
+// stopping tells systemd that the daemon is shutting down.
+func (n *sdNotifier) stopping() {
+	err := n.notify("STOPPING=1\nSTATUS=Shutting down")
+	if err != nil {
+		n.log.Warn("Unable to notify systemd", logger.Err(err))
+	}
+}
+
+// threadedWatchdog pings the systemd watchdog at half its timeout until stop
+// is closed.
+func (n *sdNotifier) threadedWatchdog(stop <-chan struct{}) {
+	if n.socket == "" || n.watchdog == 0 {
+		return
+	}
+	ticker := time.NewTicker(n.watchdog / 2)
+	defer ticker.Stop()
+	for {
+		err := n.notify("WATCHDOG=1")
+		if err != nil {
+			n.log.Warn("Unable to ping the systemd watchdog", logger.Err(err))
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// threadedProgress sends the progress of a startup phase to systemd until
+// done is closed.
+func (n *sdNotifier) threadedProgress(phase string, progress func() string, done <-chan struct{}) {
+	if n.socket == "" {
+		return
+	}
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	var last string
+	for first := true; ; first = false {
+		current := progress()
+		if first || current != last {
+			if current == "" {
+				n.status("%s: waiting for a choice in the GUI", phase)
+			} else {
+				n.status("%s: %s", phase, current)
+			}
+			last = current
+		}
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// activatedListener returns the listening socket systemd passed to the
+// daemon, or nil when the daemon was not socket activated. Only the first
+// socket is used.
+func activatedListener(log logger.Logger) (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv(envListenPID))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	fds, err := strconv.Atoi(os.Getenv(envListenFDs))
+	if err != nil || fds < 1 {
+		return nil, nil
+	}
+	os.Unsetenv(envListenPID)
+	os.Unsetenv(envListenFDs)
+	os.Unsetenv(envListenNames)
+	if fds > 1 {
+		log.Warn("systemd passed more than one socket, only the first one is used", logger.F("sockets", fds))
+	}
+	syscall.CloseOnExec(listenFDsStart)
+	f := os.NewFile(uintptr(listenFDsStart), "systemd-socket")
+	defer f.Close()
+	listener, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to use the socket passed by systemd: %w", err)
+	}
+	return listener, nil
+}
@@ -0,0 +1,113 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RotatingFile is a log file that is rotated once it reaches its maximum
+// size. The rotated files are named after the log file with .1, .2 and so on
+// appended, .1 being the most recent one.
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// OpenFile opens or creates the log file at path, creating its directory if
+// necessary. It is rotated when a write would grow it beyond maxSize bytes and
+// at most maxBackups rotated files are kept.
+func OpenFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, err
+	}
+	f := &RotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	err = f.open()
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write implements io.Writer.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		err := f.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the log file.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// open opens the log file for appending.
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = fi.Size()
+	return nil
+}
+
+// rotate moves the log file and the rotated files up by one, dropping the
+// oldest, and starts a new log file.
+func (f *RotatingFile) rotate() error {
+	err := f.file.Close()
+	f.file = nil
+	if err != nil {
+		return err
+	}
+	for i := f.maxBackups; i > 0; i-- {
+		src := f.path
+		if i > 1 {
+			src = fmt.Sprintf("%s.%d", f.path, i-1)
+		}
+		err = os.Rename(src, fmt.Sprintf("%s.%d", f.path, i))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if f.maxBackups == 0 {
+		err = os.Remove(f.path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return f.open()
+}
@@ -0,0 +1,206 @@
+// Package logger provides the leveled logger that the web wallet's packages
+// write their diagnostics to.
+//
+// Messages are written one per line as the time, the level, the message and
+// the fields as key=value pairs. The values of fields whose key names a secret,
+// such as a seed or a password, are always replaced by [REDACTED]. Seeds and
+// passwords must never be placed in a message itself.
+package logger
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a message.
+type Level int
+
+// The levels from the least to the most severe.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// levelNames are the names of the levels as they are configured and logged.
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+// redacted replaces the value of a field that holds a secret.
+const redacted = "[REDACTED]"
+
+// secretKeys are the words that mark a field as holding a secret. Session
+// IDs authenticate their holder, so they are only logged as a digest under
+// another key.
+var secretKeys = []string{"seed", "password", "passphrase", "secret", "token", "mnemonic", "session_id", "sessionid"}
+
+// timeFormat is the format of the time in front of every message.
+const timeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// String returns the name of the level.
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return "level(" + strconv.Itoa(int(l)) + ")"
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the level with the name.
+func ParseLevel(name string) (Level, error) {
+	for i, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q, must be one of %s", name, strings.Join(levelNames, ", "))
+}
+
+// Field is a key value pair that is logged with a message.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F returns a field.
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Err returns the field of an error.
+func Err(err error) Field {
+	return Field{Key: "error", Value: err}
+}
+
+// Logger writes leveled messages with fields.
+type Logger interface {
+	// Debug logs a message that is only of interest when debugging.
+	Debug(msg string, fields ...Field)
+	// Info logs a message about the normal operation of the web wallet.
+	Info(msg string, fields ...Field)
+	// Warn logs a message about a problem the web wallet recovered from.
+	Warn(msg string, fields ...Field)
+	// Error logs a message about an operation that failed.
+	Error(msg string, fields ...Field)
+	// With returns a logger that adds the fields to every message.
+	With(fields ...Field) Logger
+}
+
+// output is the writer and level shared by a logger and the loggers derived
+// from it.
+type output struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level Level
+	now   func() time.Time
+}
+
+// logger is the Logger returned by New.
+type logger struct {
+	out    *output
+	fields []Field
+}
+
+// New returns a logger that writes the messages of the level and above to w.
+func New(w io.Writer, level Level) Logger {
+	return &logger{
+		out: &output{
+			w:     w,
+			level: level,
+			now:   time.Now,
+		},
+	}
+}
+
+// Discard returns a logger that drops every message.
+func Discard() Logger {
+	return New(ioutil.Discard, LevelError+1)
+}
+
+// Debug implements Logger.
+func (l *logger) Debug(msg string, fields ...Field) {
+	l.log(LevelDebug, msg, fields)
+}
+
+// Info implements Logger.
+func (l *logger) Info(msg string, fields ...Field) {
+	l.log(LevelInfo, msg, fields)
+}
+
+// Warn implements Logger.
+func (l *logger) Warn(msg string, fields ...Field) {
+	l.log(LevelWarn, msg, fields)
+}
+
+// Error implements Logger.
+func (l *logger) Error(msg string, fields ...Field) {
+	l.log(LevelError, msg, fields)
+}
+
+// With implements Logger.
+func (l *logger) With(fields ...Field) Logger {
+	combined := make([]Field, 0, len(l.fields)+len(fields))
+	combined = append(combined, l.fields...)
+	combined = append(combined, fields...)
+	return &logger{out: l.out, fields: combined}
+}
+
+// log writes the message as a single line when its level is enabled.
+func (l *logger) log(level Level, msg string, fields []Field) {
+	if level < l.out.level {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(l.out.now().Format(timeFormat))
+	b.WriteByte(' ')
+	b.WriteString(strings.ToUpper(level.String()))
+	b.WriteByte(' ')
+	b.WriteString(escape(strings.TrimRight(msg, "\n")))
+	for _, field := range l.fields {
+		writeField(&b, field)
+	}
+	for _, field := range fields {
+		writeField(&b, field)
+	}
+	b.WriteByte('\n')
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	io.WriteString(l.out.w, b.String())
+}
+
+// writeField writes the field as key=value. Values with spaces or quotes are
+// quoted and the values of secret fields are redacted.
+func writeField(b *strings.Builder, field Field) {
+	value := redacted
+	if !isSecret(field.Key) {
+		value = fmt.Sprint(field.Value)
+	}
+	b.WriteByte(' ')
+	b.WriteString(field.Key)
+	b.WriteByte('=')
+	if value == "" || strings.ContainsAny(value, " \t\r\n\"=") {
+		value = strconv.Quote(value)
+	}
+	b.WriteString(value)
+}
+
+// isSecret returns true when the field's key names a secret.
+func isSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// escape keeps a message on a single line so that it cannot forge other log
+// entries.
+func escape(msg string) string {
+	return strings.NewReplacer("\r", `\r`, "\n", `\n`).Replace(msg)
+}
@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestRedaction tests that the values of fields that hold secrets are never
+// written, whichever logger they were added to.
+func TestRedaction(t *testing.T) {
+	tests := []struct {
+		key    string
+		secret bool
+	}{
+		{key: "password", secret: true},
+		{key: "new_password", secret: true},
+		{key: "Password", secret: true},
+		{key: "seed", secret: true},
+		{key: "primarySeed", secret: true},
+		{key: "mnemonic", secret: true},
+		{key: "passphrase", secret: true},
+		{key: "csrf_token", secret: true},
+		{key: "session_id", secret: true},
+		{key: "SessionID", secret: true},
+		{key: "wallet", secret: false},
+		{key: "session", secret: false},
+	}
+	for _, test := range tests {
+		value := "value of " + test.key
+		for _, with := range []bool{false, true} {
+			var b bytes.Buffer
+			log := New(&b, LevelDebug)
+			if with {
+				log.With(F(test.key, value)).Info("message")
+			} else {
+				log.Info("message", F(test.key, value))
+			}
+			line := b.String()
+			redactedField := " " + test.key + "=" + redacted
+			if test.secret && (strings.Contains(line, value) || !strings.Contains(line, redactedField)) {
+				t.Errorf("%v (added with With: %v): expected the value to be redacted, got %q", test.key, with, line)
+			} else if !test.secret && !strings.Contains(line, value) {
+				t.Errorf("%v (added with With: %v): expected the value to be logged, got %q", test.key, with, line)
+			}
+		}
+	}
+}
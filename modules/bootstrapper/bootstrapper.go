@@ -2,6 +2,7 @@ package bootstrapper
 
 import (
 	"archive/zip"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -10,12 +11,16 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"gitlab.com/scpcorp/ScPrime/modules"
 	"gitlab.com/scpcorp/ScPrime/modules/consensus"
 
 	"gitlab.com/scpcorp/webwallet/build"
+	"gitlab.com/scpcorp/webwallet/logger"
 )
 
 // Skipped is the value that the bootstrapper's progress is set to after it has been skipped.
@@ -24,24 +29,94 @@ const Skipped = "Skipped"
 // Closed is the value that the bootstrapper's progress is set to after it has been closed.
 const Closed = "Closed"
 
+// Failed is the value that the bootstrapper's progress is set to after it has failed.
+const Failed = "Failed"
+
+// Download settings.
+const (
+	// partialFileName is the name of the file in the data directory that the
+	// archive is downloaded to.
+	partialFileName = "consensus-latest.zip.part"
+	// validatorSuffix is appended to the partial file's name to get the name
+	// of the file that holds the ETag or Last-Modified date of the archive.
+	validatorSuffix = ".validator"
+	// downloadAttempts is the number of consecutive failed attempts after
+	// which a mirror is given up on.
+	downloadAttempts = 8
+	// minRetryDelay and maxRetryDelay bound the backoff between attempts.
+	minRetryDelay = time.Second
+	maxRetryDelay = time.Minute
+	// stallTimeout is how long a download may receive no data before it is
+	// retried.
+	stallTimeout = time.Minute
+	// interruptInterval is how often a download checks whether it was
+	// paused, stopped or has stalled.
+	interruptInterval = 250 * time.Millisecond
+	// extractSuffix is appended to the consensus database's name to get the
+	// name of the file it is extracted to before it replaces the database.
+	extractSuffix = ".extracting"
+)
+
+var (
+	// errPaused is returned when a download attempt was interrupted because
+	// the download was paused.
+	errPaused = errors.New("the download was paused")
+	// errStopped is returned when the download was interrupted because the
+	// bootstrapper was skipped or closed.
+	errStopped = errors.New("the bootstrapper was stopped")
+	// errStalled is returned when a download attempt received no data for
+	// stallTimeout.
+	errStalled = errors.New("the download stalled")
+)
+
 // LocalConsensusSize is the size in bytes of the consensus file that is stored to disk.
 var LocalConsensusSize = int64(0)
 
-var status = ""
+// status is the bootstrapper's progress as a percentage while it runs, or
+// Skipped, Closed or Failed once it has stopped. It is empty until the user
+// chose how to obtain the consensus set. statusMu guards it, since the GUI
+// changes it while the bootstrapper runs.
+var (
+	status   = ""
+	statusMu sync.Mutex
+)
+
+// pause is the pause state of the download.
+var pause struct {
+	sync.Mutex
+	paused bool
+	// err is the error that paused the download, or nil when the user paused
+	// it.
+	err error
+}
+
+// downloaded is the number of bytes of the consensus set that have been
+// downloaded.
+var downloaded int64
+
+// log is the logger the bootstrapper writes its messages to.
+var log = logger.New(os.Stdout, logger.LevelInfo)
+
+// SetLogger sets the logger the bootstrapper writes its messages to.
+func SetLogger(l logger.Logger) {
+	log = l
+}
 
 // Skip bootstrapping consensus from consensus.scpri.me
 func Skip() {
-	status = Skipped
+	setStatus(Skipped)
 }
 
 // Close bootstrapping consensus module
 func Close() {
-	fmt.Println("Closing bootstrapper...")
-	status = Closed
+	log.Info("Closing bootstrapper")
+	setStatus(Closed)
 }
 
 // Initialize bootstrapping consensus from consensus.scpri.me
 func Initialize() {
+	statusMu.Lock()
+	defer statusMu.Unlock()
 	if status == "" {
 		status = "0"
 	}
@@ -49,15 +124,39 @@ func Initialize() {
 
 // Progress returns the bootstrapper's progress as a percentage.
 func Progress() string {
-	_, err := strconv.Atoi(status)
+	progress := currentStatus()
+	_, err := strconv.Atoi(progress)
 	if err != nil {
-		return status
+		return progress
 	}
-	return status + `%`
+	return progress + `%`
+}
+
+// currentStatus returns the bootstrapper's status.
+func currentStatus() string {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	return status
+}
+
+// setStatus sets the bootstrapper's status.
+func setStatus(s string) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	status = s
+}
+
+// BytesDownloaded returns the number of bytes of the consensus set that have
+// been downloaded.
+func BytesDownloaded() int64 {
+	return atomic.LoadInt64(&downloaded)
 }
 
 // Start begins the process of bootstrapping consensus from consensus.scpri.me.
-func Start(dataDir string) {
+// The downloaded archive is verified against its manifest before it is
+// installed. Start returns an error when the consensus set could not be
+// bootstrapped.
+func Start(dataDir string) error {
 	consensusDir := filepath.Join(dataDir, modules.ConsensusDir)
 	consensusDb := filepath.Join(consensusDir, consensus.DatabaseFilename)
 	_, err := os.Stat(consensusDir)
@@ -67,7 +166,7 @@ func Start(dataDir string) {
 	if err != nil {
 		// Unable to create the consensus directory.
 		// Return early and let the consensus module create the directory.
-		return
+		return nil
 	}
 	fi, err := os.Stat(consensusDb)
 	if !errors.Is(err, os.ErrNotExist) {
@@ -75,141 +174,469 @@ func Start(dataDir string) {
 		if LocalConsensusSize > build.ConsensusSizeByteCheck() {
 			// There is no need to bootstrap consensus because the on-disk consensus size is
 			// larger than the consensus size byte check.
-			return
+			return nil
 		}
 	}
 	// Consensus does not exist. Block until user chooses to bootstrap it or build it.
-	for status == "" {
+	for currentStatus() == "" {
 		time.Sleep(25 * time.Millisecond)
 	}
 	// Return early if the bootstrapper was skipped or closed.
-	if status == Skipped || status == Closed {
-		return
+	if stopped() {
+		return nil
 	}
-	remoteConsensusSize, err := requestRemoteConsensusSize()
+	key, err := signingKey()
 	if err != nil {
-		// Do not download consensus-latest.zip because something is wrong.
-		fmt.Printf("\nBootstrapper failed to obtain the remote consensus size. %v\n", err)
-		return
+		return fail(err)
 	}
-	tmp, err := ioutil.TempFile(os.TempDir(), "scprime-consensus")
-	if err != nil {
-		// Unable to create the temporary file to download the consensus database to.
-		// Return early and let the consensus module create the directory from scratch.
-		return
+	if key == nil {
+		log.Warn("No consensus signing key is pinned, the archive is only verified against the checksum in its manifest")
 	}
-	defer os.Remove(tmp.Name())
-	var sem = make(chan int, 1)
-	sem <- 1
-	go func(filepath string) {
-		consensusDownload(filepath)
-		<-sem
-	}(tmp.Name())
+	if archive := importedArchive(); archive != "" {
+		return install(archive, consensusDb, key)
+	}
+	// The archive is downloaded to a partial file in the data directory, so
+	// that a download that was interrupted, or a previous run, can be resumed.
+	partial := filepath.Join(dataDir, partialFileName)
+	var m manifest
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		m, err = fetch(partial, key)
+		done <- err
+	}()
 	// Updates the status.
-	status = `0`
-	for i := 1; true; i++ {
-		updateStatus(tmp.Name(), remoteConsensusSize)
-		if len(sem) == 0 {
-			break
+	setProgress(`0`)
+	var downloadErr error
+	for downloading := true; downloading; {
+		updateStatus()
+		select {
+		case downloadErr = <-done:
+			downloading = false
+		case <-time.After(1 * time.Second):
 		}
-		time.Sleep(1 * time.Second)
 	}
-	if status == Skipped || status == Closed {
-		return
+	if stopped() || errors.Is(downloadErr, errStopped) {
+		return nil
+	}
+	if downloadErr != nil {
+		return fail(fmt.Errorf("unable to download the consensus set: %w", downloadErr))
+	}
+	setProgress(`99`)
+	err = verifyArchive(partial, m)
+	if err != nil {
+		// Start over the next time instead of resuming a corrupt archive.
+		removePartial(partial)
+		return fail(fmt.Errorf("refusing to install the downloaded consensus set: %w", err))
+	}
+	err = extract(partial, consensusDb)
+	if errors.Is(err, errStopped) {
+		return nil
+	} else if err != nil {
+		return fail(fmt.Errorf("unable to decompress the consensus set: %w", err))
 	}
-	status = `99`
-	decompress(tmp.Name(), consensusDb)
-	status = `100`
+	removePartial(partial)
+	telemetry.finish()
+	setProgress(`100`)
+	return nil
 }
 
-// Decompress the zip archive; move consensus.db to the destination.
-func decompress(src string, dest string) error {
-	_, err := os.Stat(dest)
-	if !errors.Is(err, os.ErrNotExist) {
-		os.Remove(dest)
+// fail marks the bootstrapper as failed and returns err.
+func fail(err error) error {
+	setStatus(Failed)
+	return err
+}
+
+// Pause pauses the download of the consensus set. The part that has been
+// downloaded is kept and the download continues from there when it is
+// resumed.
+func Pause() {
+	pause.Lock()
+	defer pause.Unlock()
+	if !pause.paused {
+		log.Info("Pausing the consensus set download")
+	}
+	pause.paused = true
+	pause.err = nil
+}
+
+// Resume resumes the download of the consensus set after it was paused,
+// either by the user or because it kept failing.
+func Resume() {
+	pause.Lock()
+	defer pause.Unlock()
+	if pause.paused {
+		log.Info("Resuming the consensus set download")
+	}
+	pause.paused = false
+	pause.err = nil
+}
+
+// Paused returns whether the download of the consensus set is paused.
+func Paused() bool {
+	pause.Lock()
+	defer pause.Unlock()
+	return pause.paused
+}
+
+// DownloadError returns the error that paused the download after it failed
+// repeatedly, or nil when the download was not paused by a failure.
+func DownloadError() error {
+	pause.Lock()
+	defer pause.Unlock()
+	return pause.err
+}
+
+// pauseWithError pauses the download because it failed with err.
+func pauseWithError(err error) {
+	pause.Lock()
+	defer pause.Unlock()
+	pause.paused = true
+	pause.err = err
+}
+
+// extract waits until there is room for the consensus database in the
+// archive and then decompresses it to dest.
+func extract(archive string, dest string) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
 	}
+	f := databaseFile(r)
+	r.Close()
+	if f == nil {
+		return fmt.Errorf("the archive does not contain %s", consensus.DatabaseFilename)
+	}
+	err = waitForSpace(filepath.Dir(dest), int64(f.UncompressedSize64))
+	if err != nil {
+		return err
+	}
+	return decompress(archive, dest)
+}
+
+// databaseFile returns the consensus database in the zip archive, or nil if
+// there is none.
+func databaseFile(r *zip.ReadCloser) *zip.File {
+	for _, f := range r.File {
+		if f.Name == consensus.DatabaseFilename {
+			return f
+		}
+	}
+	return nil
+}
+
+// Decompress the zip archive; move consensus.db to the destination. The
+// database is extracted to a temporary file next to dest that is renamed to
+// dest once it is complete, so that an existing database is only replaced
+// by a complete one.
+func decompress(src string, dest string) error {
 	r, err := zip.OpenReader(src)
 	if err != nil {
 		return err
 	}
 	defer r.Close()
-	for _, f := range r.File {
-		if f.Name != "consensus.db" {
-			continue
+	f := databaseFile(r)
+	if f == nil {
+		return fmt.Errorf("the archive does not contain %s", consensus.DatabaseFilename)
+	}
+	perm := f.Mode().Perm()
+	if perm == 0 {
+		perm = 0600
+	}
+	tmp := dest + extractSuffix
+	outFile, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	rc, err := f.Open()
+	if err != nil {
+		outFile.Close()
+		return err
+	}
+	telemetry.begin(StageDecompressing, 0, int64(f.UncompressedSize64))
+	_, err = io.Copy(io.MultiWriter(outFile, telemetryWriter{}), rc)
+	rc.Close()
+	if err == nil {
+		err = outFile.Sync()
+	}
+	if closeErr := outFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
+// download downloads the consensus database archive at url to target. A
+// partial archive that is already at target is resumed with a range request.
+// Failed attempts are retried with an exponential backoff until
+// downloadAttempts attempts in a row have failed. download returns errStopped
+// when the bootstrapper is skipped or closed.
+func download(url string, target string, size int64) error {
+	failures := 0
+	delay := minRetryDelay
+	for {
+		if err := waitWhilePaused(); err != nil {
+			return err
 		}
-		outFile, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-		if err != nil {
+		progressed, err := downloadAttempt(url, target, size)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, errStopped) {
 			return err
 		}
-		rc, err := f.Open()
-		if err != nil {
+		if errors.Is(err, errPaused) {
+			continue
+		}
+		if progressed {
+			failures = 0
+			delay = minRetryDelay
+		}
+		failures++
+		if failures >= downloadAttempts {
 			return err
 		}
-		for {
-			_, err := io.CopyN(outFile, rc, 1024)
-			if err != nil {
-				if err == io.EOF {
-					break
-				}
-				return err
-			}
+		log.Warn("Bootstrapper download failed, retrying", logger.F("attempt", failures), logger.F("delay", delay), logger.Err(err))
+		if err := sleep(delay); err != nil {
+			return err
 		}
-		// Close the file without defer to close before next iteration of loop
-		outFile.Close()
-		rc.Close()
+		delay = nextRetryDelay(delay)
 	}
-	return nil
 }
 
-// Returns the size of the latest consensus database in bytes.
-func requestRemoteConsensusSize() (int64, error) {
-	resp, err := http.Head("https://consensus.scprime/releases/consensus-latest.zip")
-	if err != nil {
-		return 0, err
+// nextRetryDelay returns the delay before the attempt after the one that was
+// delayed by delay. The delay doubles up to maxRetryDelay.
+func nextRetryDelay(delay time.Duration) time.Duration {
+	delay *= 2
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
 	}
-	return resp.ContentLength, nil
+	return delay
 }
 
-// Downloads the consensus databse to a local file without loading the whole file into memory.
-func consensusDownload(target string) error {
-	// Get the data
-	resp, err := http.Get("https://consensus.scprime/releases/consensus-latest.zip")
+// downloadAttempt downloads the rest of the archive to target without
+// loading it into memory. It returns whether any bytes were downloaded.
+func downloadAttempt(url string, target string, size int64) (bool, error) {
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE, 0600)
 	if err != nil {
-		return err
+		return false, err
 	}
-	defer resp.Body.Close()
-	// Create the file
-	out, err := os.Create(target)
+	defer out.Close()
+	offset, err := out.Seek(0, io.SeekEnd)
 	if err != nil {
-		return err
+		return false, err
+	}
+	if size > 0 && offset == size {
+		// The archive was downloaded completely before.
+		telemetry.begin(StageDownloading, offset, size)
+		return false, nil
+	}
+	if size > 0 && offset > size {
+		// The partial file is of a different archive.
+		if offset, err = restart(out, target); err != nil {
+			return false, err
+		}
 	}
+	telemetry.begin(StageDownloading, offset, size)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		// Only resume when the archive has not changed since the partial
+		// file was started; the server sends the whole archive otherwise.
+		if validator := readValidator(target); validator != "" {
+			req.Header.Set("If-Range", validator)
+		}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		var start int64
+		_, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &start)
+		if err != nil || start != offset {
+			return false, fmt.Errorf("unexpected content range %q", resp.Header.Get("Content-Range"))
+		}
+		log.Info("Bootstrapper resuming the download", logger.F("offset", offset))
+	case http.StatusOK:
+		// The server does not support ranges, or the archive has changed.
+		if offset > 0 {
+			log.Info("Bootstrapper restarting the download from the beginning")
+			if _, err := restart(out, target); err != nil {
+				return false, err
+			}
+			telemetry.begin(StageDownloading, 0, size)
+		}
+		if err := writeValidator(target, resp.Header); err != nil {
+			return false, err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial file is larger than the archive.
+		if _, err := restart(out, target); err != nil {
+			return false, err
+		}
+		return false, errors.New("the partial download does not match the archive")
+	default:
+		return false, fmt.Errorf("unexpected response %q", resp.Status)
+	}
+
+	// Stop the request when the download is paused, stopped or stalls.
+	var interrupted atomic.Value
+	go func() {
+		ticker := time.NewTicker(interruptInterval)
+		defer ticker.Stop()
+		last, lastProgress := BytesDownloaded(), time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if stopped() {
+				interrupted.Store(errStopped)
+			} else if Paused() {
+				interrupted.Store(errPaused)
+			} else if n := BytesDownloaded(); n != last {
+				last, lastProgress = n, time.Now()
+			} else if time.Since(lastProgress) > stallTimeout {
+				interrupted.Store(errStalled)
+			}
+			if interrupted.Load() != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
 	// Write the body to file
+	progressed := false
 	for {
-		_, err := io.CopyN(out, resp.Body, 1024)
+		n, err := io.CopyN(out, resp.Body, 32<<10)
+		atomic.AddInt64(&downloaded, n)
+		telemetry.add(n)
+		progressed = progressed || n > 0
+		if err == io.EOF {
+			return progressed, out.Sync()
+		}
 		if err != nil {
-			if err == io.EOF {
-				break
+			if reason, ok := interrupted.Load().(error); ok {
+				return progressed, reason
 			}
-			return err
+			return progressed, err
 		}
-		_, err = strconv.Atoi(status)
-		if err != nil {
-			break
+	}
+}
+
+// restart truncates the partial file and removes its validator so that the
+// download starts from the beginning.
+func restart(out *os.File, target string) (int64, error) {
+	err := out.Truncate(0)
+	if err != nil {
+		return 0, err
+	}
+	err = os.Remove(target + validatorSuffix)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	return out.Seek(0, io.SeekStart)
+}
+
+// readValidator returns the ETag or Last-Modified date of the archive that
+// the partial file is a part of, or "" if it is unknown.
+func readValidator(target string) string {
+	validator, err := ioutil.ReadFile(target + validatorSuffix)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(validator))
+}
+
+// writeValidator records the ETag or Last-Modified date of the archive that
+// is being downloaded to the partial file. A weak ETag cannot be used to
+// resume a download, so it is not recorded.
+func writeValidator(target string, header http.Header) error {
+	validator := header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = header.Get("Last-Modified")
+	}
+	if validator == "" {
+		return nil
+	}
+	return ioutil.WriteFile(target+validatorSuffix, []byte(validator+"\n"), 0600)
+}
+
+// removePartial removes the partial file and its validator once the archive
+// has been installed.
+func removePartial(target string) {
+	for _, path := range []string{target, target + validatorSuffix} {
+		err := os.Remove(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warn("Bootstrapper failed to remove the downloaded archive", logger.F("path", path), logger.Err(err))
+		}
+	}
+}
+
+// stopped returns whether the bootstrapper was skipped or closed.
+func stopped() bool {
+	_, err := strconv.Atoi(currentStatus())
+	return err != nil
+}
+
+// waitWhilePaused blocks while the download is paused. It returns errStopped
+// when the bootstrapper is skipped or closed.
+func waitWhilePaused() error {
+	for Paused() {
+		if stopped() {
+			return errStopped
 		}
+		time.Sleep(interruptInterval)
+	}
+	if stopped() {
+		return errStopped
 	}
-	out.Close()
 	return nil
 }
 
-// updates the status.
-func updateStatus(filepath string, size int64) {
-	_, err := strconv.Atoi(status)
-	if err != nil {
-		return
+// sleep waits for the duration before a download is retried. It returns
+// early when the download is paused, and returns errStopped when the
+// bootstrapper is skipped or closed.
+func sleep(d time.Duration) error {
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) && !Paused() {
+		if stopped() {
+			return errStopped
+		}
+		time.Sleep(interruptInterval)
 	}
-	fi, _ := os.Stat(filepath)
-	progress := int(float64(fi.Size()) / float64(size) * float64(100))
+	return nil
+}
+
+// updates the status from the progress of the download. It stays below 99
+// until the database has been installed.
+func updateStatus() {
+	progress := telemetry.percent()
 	if progress > 0 && progress < 99 {
-		status = fmt.Sprintf("%d", progress)
+		setProgress(fmt.Sprintf("%d", progress))
+	}
+}
+
+// setProgress sets the bootstrapper's status to the progress, unless the
+// bootstrapper was skipped or closed in the meantime.
+func setProgress(progress string) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	if _, err := strconv.Atoi(status); err == nil {
+		status = progress
 	}
 }
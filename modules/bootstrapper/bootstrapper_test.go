@@ -0,0 +1,269 @@
+package bootstrapper
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gitlab.com/scpcorp/webwallet/logger"
+)
+
+// testArchive is the archive that the test servers serve.
+var testArchive = bytes.Repeat([]byte("consensus archive "), 4096)
+
+// testETag is the ETag of testArchive.
+const testETag = `"v1"`
+
+// serveArchive serves testArchive with its ETag, honoring Range and If-Range
+// requests.
+func serveArchive(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("ETag", testETag)
+	http.ServeContent(w, req, "consensus-latest.zip", time.Unix(1600000000, 0), bytes.NewReader(testArchive))
+}
+
+// statusRecorder records the status code of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status *int32
+}
+
+// WriteHeader records the status code and writes it.
+func (r statusRecorder) WriteHeader(code int) {
+	atomic.StoreInt32(r.status, int32(code))
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// setupTest discards the bootstrapper's log messages and starts the
+// bootstrapper so that downloads are not treated as stopped.
+func setupTest(t *testing.T) {
+	prevLog, prevStatus := log, currentStatus()
+	log = logger.New(ioutil.Discard, logger.LevelError)
+	setStatus("0")
+	t.Cleanup(func() {
+		log = prevLog
+		setStatus(prevStatus)
+	})
+}
+
+// TestDownloadAttempt tests that a download attempt resumes a partial
+// archive with a range request when the archive has not changed, and starts
+// over otherwise.
+func TestDownloadAttempt(t *testing.T) {
+	offset := int64(1000)
+	tests := []struct {
+		name      string
+		partial   []byte
+		validator string
+		size      int64
+		handler   http.HandlerFunc
+		// status is the status code the server responds with, or 0 when no
+		// request is expected.
+		status        int
+		wantErr       bool
+		wantFile      []byte
+		wantValidator string
+	}{
+		{
+			name:          "fresh download",
+			handler:       serveArchive,
+			status:        http.StatusOK,
+			wantFile:      testArchive,
+			wantValidator: testETag,
+		},
+		{
+			name:          "resumed",
+			partial:       testArchive[:offset],
+			validator:     testETag,
+			handler:       serveArchive,
+			status:        http.StatusPartialContent,
+			wantFile:      testArchive,
+			wantValidator: testETag,
+		},
+		{
+			name:          "archive changed",
+			partial:       testArchive[:offset],
+			validator:     `"v0"`,
+			handler:       serveArchive,
+			status:        http.StatusOK,
+			wantFile:      testArchive,
+			wantValidator: testETag,
+		},
+		{
+			name:      "ranges not supported",
+			partial:   testArchive[:offset],
+			validator: testETag,
+			handler: func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write(testArchive)
+			},
+			status:   http.StatusOK,
+			wantFile: testArchive,
+		},
+		{
+			name:      "partial larger than archive",
+			partial:   append(append([]byte{}, testArchive...), "extra"...),
+			validator: testETag,
+			handler:   serveArchive,
+			status:    http.StatusRequestedRangeNotSatisfiable,
+			wantErr:   true,
+			wantFile:  []byte{},
+		},
+		{
+			name:      "unexpected content range",
+			partial:   testArchive[:offset],
+			validator: testETag,
+			handler: func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(testArchive)-1, len(testArchive)))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(testArchive)
+			},
+			status:        http.StatusPartialContent,
+			wantErr:       true,
+			wantFile:      testArchive[:offset],
+			wantValidator: testETag,
+		},
+		{
+			name: "server error",
+			handler: func(w http.ResponseWriter, req *http.Request) {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			},
+			status:   http.StatusServiceUnavailable,
+			wantErr:  true,
+			wantFile: []byte{},
+		},
+		{
+			name:          "already complete",
+			partial:       testArchive,
+			validator:     testETag,
+			size:          int64(len(testArchive)),
+			wantFile:      testArchive,
+			wantValidator: testETag,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupTest(t)
+			var status int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if test.handler == nil {
+					t.Error("unexpected request")
+					return
+				}
+				test.handler(statusRecorder{w, &status}, req)
+			}))
+			defer srv.Close()
+			target := filepath.Join(t.TempDir(), partialFileName)
+			if test.partial != nil {
+				if err := ioutil.WriteFile(target, test.partial, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if test.validator != "" {
+				if err := ioutil.WriteFile(target+validatorSuffix, []byte(test.validator+"\n"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err := downloadAttempt(srv.URL, target, test.size)
+			if test.wantErr && err == nil {
+				t.Fatal("expected an error")
+			} else if !test.wantErr && err != nil {
+				t.Fatal(err)
+			}
+			if got := int(atomic.LoadInt32(&status)); got != test.status {
+				t.Errorf("expected status %v, got %v", test.status, got)
+			}
+			file, err := ioutil.ReadFile(target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(file, test.wantFile) {
+				t.Errorf("expected a file of %v bytes, got %v bytes", len(test.wantFile), len(file))
+			}
+			if got := readValidator(target); got != test.wantValidator {
+				t.Errorf("expected validator %q, got %q", test.wantValidator, got)
+			}
+		})
+	}
+}
+
+// TestDownloadResumesAfterFailure tests that a download that fails part way
+// through is retried and resumed where it stopped.
+func TestDownloadResumesAfterFailure(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	setupTest(t)
+	half := len(testArchive) / 2
+	var requests int32
+	var resumedRange atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// Drop the connection after half of the archive.
+			w.Header().Set("ETag", testETag)
+			w.Header().Set("Content-Length", strconv.Itoa(len(testArchive)))
+			w.Write(testArchive[:half])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		resumedRange.Store(req.Header.Get("Range"))
+		serveArchive(w, req)
+	}))
+	defer srv.Close()
+	target := filepath.Join(t.TempDir(), partialFileName)
+
+	if err := download(srv.URL, target, int64(len(testArchive))); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Fatalf("expected 2 requests, got %v", n)
+	}
+	if got, want := resumedRange.Load(), fmt.Sprintf("bytes=%d-", half); got != want {
+		t.Fatalf("expected the retry to request %q, got %q", want, got)
+	}
+	file, err := ioutil.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(file, testArchive) {
+		t.Fatalf("expected a file of %v bytes, got %v bytes", len(testArchive), len(file))
+	}
+}
+
+// TestNextRetryDelay tests that the delay between download attempts doubles
+// up to maxRetryDelay.
+func TestNextRetryDelay(t *testing.T) {
+	tests := []struct {
+		delay time.Duration
+		want  time.Duration
+	}{
+		{minRetryDelay, 2 * minRetryDelay},
+		{2 * time.Second, 4 * time.Second},
+		{16 * time.Second, 32 * time.Second},
+		{32 * time.Second, maxRetryDelay},
+		{maxRetryDelay, maxRetryDelay},
+	}
+	for _, test := range tests {
+		if got := nextRetryDelay(test.delay); got != test.want {
+			t.Errorf("nextRetryDelay(%v): expected %v, got %v", test.delay, test.want, got)
+		}
+	}
+	// Eight failed attempts in a row wait for about three minutes in total
+	// before the mirror is given up on.
+	var total time.Duration
+	delay := minRetryDelay
+	for i := 1; i < downloadAttempts; i++ {
+		total += delay
+		delay = nextRetryDelay(delay)
+	}
+	if want := 1*time.Second + 2*time.Second + 4*time.Second + 8*time.Second + 16*time.Second + 32*time.Second + time.Minute; total != want {
+		t.Fatalf("expected a total delay of %v, got %v", want, total)
+	}
+}
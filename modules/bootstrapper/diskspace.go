@@ -0,0 +1,69 @@
+package bootstrapper
+
+import (
+	"errors"
+	"fmt"
+
+	"gitlab.com/scpcorp/webwallet/logger"
+)
+
+var (
+	// ErrInsufficientSpace is returned when there is not enough free disk
+	// space to bootstrap the consensus set.
+	ErrInsufficientSpace = errors.New("not enough free disk space")
+	// errFreeSpaceUnknown is returned by freeSpace on platforms where the
+	// free space cannot be determined.
+	errFreeSpaceUnknown = errors.New("the free disk space cannot be determined on this platform")
+)
+
+// diskFree returns the number of bytes that are available in the file system
+// that holds dir. It is replaced by tests.
+var diskFree = freeSpace
+
+// checkSpace checks that the file system that holds dir has room for need
+// more bytes. The check is skipped when the free space cannot be determined.
+func checkSpace(dir string, need int64) error {
+	free, err := diskFree(dir)
+	if errors.Is(err, errFreeSpaceUnknown) {
+		log.Warn("Bootstrapper is unable to check the free disk space", logger.F("dir", dir))
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to check the free disk space in %s: %w", dir, err)
+	}
+	if need > 0 && uint64(need) > free {
+		return fmt.Errorf("%w in %s: %s are needed but only %s are free", ErrInsufficientSpace, dir, formatBytes(uint64(need)), formatBytes(free))
+	}
+	return nil
+}
+
+// waitForSpace blocks until the file system that holds dir has room for need
+// more bytes. While it does not, the bootstrapper is paused with
+// ErrInsufficientSpace so that the user can free space and resume it.
+// waitForSpace returns errStopped when the bootstrapper is skipped or closed.
+func waitForSpace(dir string, need int64) error {
+	for {
+		err := checkSpace(dir, need)
+		if !errors.Is(err, ErrInsufficientSpace) {
+			return err
+		}
+		log.Error("Bootstrapper paused because there is not enough free disk space", logger.Err(err))
+		pauseWithError(err)
+		if err := waitWhilePaused(); err != nil {
+			return err
+		}
+	}
+}
+
+// formatBytes formats a number of bytes with a binary unit.
+func formatBytes(n uint64) string {
+	const unit = 1 << 10
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
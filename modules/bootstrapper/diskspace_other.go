@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package bootstrapper
+
+// freeSpace reports that the free space cannot be determined on this
+// platform.
+func freeSpace(dir string) (uint64, error) {
+	return 0, errFreeSpaceUnknown
+}
@@ -0,0 +1,202 @@
+package bootstrapper
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gitlab.com/scpcorp/ScPrime/modules/consensus"
+)
+
+// setFreeSpace makes the file systems report free bytes of free space, or
+// fail with err when it is not nil, until the test has finished.
+func setFreeSpace(t *testing.T, free *uint64, err error) {
+	prev := diskFree
+	diskFree = func(string) (uint64, error) {
+		return atomic.LoadUint64(free), err
+	}
+	t.Cleanup(func() {
+		diskFree = prev
+		Resume()
+	})
+}
+
+// TestWaitForSpace tests that the bootstrapper is paused while there is not
+// enough free disk space, and continues once space was freed and it was
+// resumed.
+func TestWaitForSpace(t *testing.T) {
+	errDisk := errors.New("disk error")
+	tests := []struct {
+		name    string
+		free    uint64
+		freeErr error
+		need    int64
+		// freed is the free space after the user freed some while the
+		// bootstrapper was paused, or 0 when the bootstrapper is skipped
+		// instead.
+		freed    uint64
+		stopped  bool
+		wantErr  error
+		wantWait bool
+	}{
+		{name: "enough space", free: 100, need: 100},
+		{name: "nothing needed", free: 0, need: 0},
+		{name: "free space unknown", freeErr: errFreeSpaceUnknown, need: 100},
+		{name: "free space error", freeErr: errDisk, need: 100, wantErr: errDisk},
+		{name: "space freed", free: 99, need: 100, freed: 100, wantWait: true},
+		{name: "skipped", free: 99, need: 100, stopped: true, wantErr: errStopped},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupTest(t)
+			free := test.free
+			setFreeSpace(t, &free, test.freeErr)
+			if test.stopped {
+				setStatus(Skipped)
+			}
+			waited := make(chan bool, 1)
+			if test.freed > 0 {
+				go func() {
+					for !Paused() {
+						time.Sleep(time.Millisecond)
+					}
+					paused := errors.Is(DownloadError(), ErrInsufficientSpace)
+					atomic.StoreUint64(&free, test.freed)
+					Resume()
+					waited <- paused
+				}()
+			}
+
+			err := waitForSpace(t.TempDir(), test.need)
+			if test.wantErr == nil && err != nil {
+				t.Fatal(err)
+			} else if test.wantErr != nil && !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected %v, got %v", test.wantErr, err)
+			}
+			if test.wantWait && !<-waited {
+				t.Fatal("the bootstrapper was not paused with ErrInsufficientSpace")
+			}
+			if !test.wantWait && !test.stopped && Paused() {
+				t.Fatal("the bootstrapper was paused")
+			}
+		})
+	}
+}
+
+// writeTestArchive writes a zip archive that holds the files to path.
+func writeTestArchive(t *testing.T, path string, files map[string][]byte) {
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for name, content := range files {
+		f, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, b.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestExtract tests that the consensus database is only replaced by a
+// completely extracted one, and that no partially extracted database is left
+// behind.
+func TestExtract(t *testing.T) {
+	oldDatabase := []byte("old consensus database")
+	newDatabase := bytes.Repeat([]byte("new consensus database "), 1024)
+	tests := []struct {
+		name    string
+		files   map[string][]byte
+		corrupt bool
+		free    uint64
+		wantErr bool
+		wantDb  []byte
+	}{
+		{
+			name:   "installed",
+			files:  map[string][]byte{consensus.DatabaseFilename: newDatabase},
+			free:   1 << 30,
+			wantDb: newDatabase,
+		},
+		{
+			name:    "no database in archive",
+			files:   map[string][]byte{"other.db": newDatabase},
+			free:    1 << 30,
+			wantErr: true,
+			wantDb:  oldDatabase,
+		},
+		{
+			name:    "corrupt database",
+			files:   map[string][]byte{consensus.DatabaseFilename: newDatabase},
+			corrupt: true,
+			free:    1 << 30,
+			wantErr: true,
+			wantDb:  oldDatabase,
+		},
+		{
+			name:    "not enough space",
+			files:   map[string][]byte{consensus.DatabaseFilename: newDatabase},
+			free:    uint64(len(newDatabase)) - 1,
+			wantErr: true,
+			wantDb:  oldDatabase,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupTest(t)
+			free := test.free
+			setFreeSpace(t, &free, nil)
+			if test.free < uint64(len(newDatabase)) {
+				// Skip the bootstrapper instead of waiting for space.
+				setStatus(Skipped)
+			}
+			dir := t.TempDir()
+			archive := filepath.Join(dir, archiveName)
+			writeTestArchive(t, archive, test.files)
+			if test.corrupt {
+				b, err := ioutil.ReadFile(archive)
+				if err != nil {
+					t.Fatal(err)
+				}
+				i := bytes.Index(b, newDatabase)
+				b[i+len(newDatabase)/2] ^= 0xff
+				if err := ioutil.WriteFile(archive, b, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			db := filepath.Join(dir, consensus.DatabaseFilename)
+			if err := ioutil.WriteFile(db, oldDatabase, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			err := extract(archive, db)
+			if test.wantErr && err == nil {
+				t.Fatal("expected an error")
+			} else if !test.wantErr && err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadFile(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, test.wantDb) {
+				t.Errorf("expected a database of %v bytes, got %v bytes", len(test.wantDb), len(got))
+			}
+			if _, err := os.Stat(db + extractSuffix); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("the partially extracted database was left behind: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package bootstrapper
+
+import "syscall"
+
+// freeSpace returns the number of bytes that are available to the user in
+// the file system that holds dir.
+func freeSpace(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
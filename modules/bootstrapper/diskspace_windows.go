@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package bootstrapper
+
+import "golang.org/x/sys/windows"
+
+// freeSpace returns the number of bytes that are available to the user in
+// the file system that holds dir.
+func freeSpace(dir string) (uint64, error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	var free uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &free, nil, nil); err != nil {
+		return 0, err
+	}
+	return free, nil
+}
@@ -0,0 +1,142 @@
+package bootstrapper
+
+import (
+	"archive/zip"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gitlab.com/scpcorp/ScPrime/modules/consensus"
+
+	"gitlab.com/scpcorp/webwallet/logger"
+)
+
+// errBootstrapStarted is returned when an archive is imported after the
+// consensus set is already being downloaded or bootstrapping was skipped.
+var errBootstrapStarted = errors.New("the consensus set is already being bootstrapped or bootstrapping was skipped")
+
+// imported is the archive that was chosen to be imported instead of
+// downloaded.
+var imported struct {
+	sync.Mutex
+	path string
+}
+
+// Import bootstraps the consensus set from an archive on this machine instead
+// of downloading it, for machines that are not online. When a manifest named
+// consensus-latest.json is next to the archive, the archive is verified
+// against it before it is installed. The manifest is required when a signing
+// key is pinned. Import fails once bootstrapping has started or was skipped.
+func Import(path string) error {
+	if currentStatus() != "" {
+		return errBootstrapStarted
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if err := checkArchive(path); err != nil {
+		return err
+	}
+	key, err := signingKey()
+	if err != nil {
+		return err
+	}
+	if _, err := readManifest(path, key); err != nil {
+		return err
+	}
+	// The archive was being checked while the user could still start or
+	// skip bootstrapping, so the status is checked again before it is
+	// chosen.
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	if status != "" {
+		return errBootstrapStarted
+	}
+	imported.Lock()
+	imported.path = path
+	imported.Unlock()
+	log.Info("Importing the consensus set", logger.F("path", path))
+	status = "0"
+	return nil
+}
+
+// Importing returns whether the consensus set is imported from an archive on
+// this machine.
+func Importing() bool {
+	return importedArchive() != ""
+}
+
+// importedArchive returns the path of the archive that is imported, or "" if
+// the consensus set is downloaded.
+func importedArchive() string {
+	imported.Lock()
+	defer imported.Unlock()
+	return imported.path
+}
+
+// checkArchive checks that the file at path is a zip archive that holds a
+// consensus database.
+func checkArchive(path string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("%s is not a consensus set archive: %w", path, err)
+	}
+	defer r.Close()
+	if databaseFile(r) == nil {
+		return fmt.Errorf("%s does not contain %s", path, consensus.DatabaseFilename)
+	}
+	return nil
+}
+
+// readManifest reads the manifest next to the archive at path. It returns nil
+// when there is none and no signing key is pinned.
+func readManifest(path string, key ed25519.PublicKey) (*manifest, error) {
+	manifestPath := filepath.Join(filepath.Dir(path), manifestName)
+	b, err := ioutil.ReadFile(manifestPath)
+	if errors.Is(err, os.ErrNotExist) && key == nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read the manifest: %w", err)
+	}
+	var m manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("unable to decode %s: %w", manifestPath, err)
+	}
+	if err := m.check(key); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// install verifies the imported archive against its manifest, if there is
+// one, and installs its consensus database. The archive is left in place.
+func install(archive string, consensusDb string, key ed25519.PublicKey) error {
+	setProgress(`99`)
+	m, err := readManifest(archive, key)
+	if err != nil {
+		return fail(fmt.Errorf("refusing to install the imported consensus set: %w", err))
+	}
+	if m != nil {
+		err = verifyArchive(archive, *m)
+		if err != nil {
+			return fail(fmt.Errorf("refusing to install the imported consensus set: %w", err))
+		}
+	} else {
+		log.Warn("Installing the imported consensus set without a manifest to verify it against")
+	}
+	err = extract(archive, consensusDb)
+	if errors.Is(err, errStopped) {
+		return nil
+	} else if err != nil {
+		return fail(fmt.Errorf("unable to decompress the consensus set: %w", err))
+	}
+	telemetry.finish()
+	setProgress(`100`)
+	return nil
+}
@@ -0,0 +1,75 @@
+package bootstrapper
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"gitlab.com/scpcorp/ScPrime/modules/consensus"
+)
+
+// TestImportOnceStarted tests that an archive cannot be imported once the
+// consensus set is being downloaded or bootstrapping was skipped.
+func TestImportOnceStarted(t *testing.T) {
+	tests := []struct {
+		status  string
+		started bool
+	}{
+		{status: "", started: false},
+		{status: "0", started: true},
+		{status: "42", started: true},
+		{status: Skipped, started: true},
+		{status: Closed, started: true},
+	}
+	for _, test := range tests {
+		setupTest(t)
+		setStatus(test.status)
+		err := Import(filepath.Join(t.TempDir(), archiveName))
+		if started := errors.Is(err, errBootstrapStarted); started != test.started {
+			t.Errorf("status %q: expected the import to be rejected as started: %v, got %v", test.status, test.started, err)
+		}
+		if err == nil {
+			t.Errorf("status %q: a missing archive was imported", test.status)
+		}
+		if Importing() {
+			t.Fatalf("status %q: the archive was chosen to be imported", test.status)
+		}
+	}
+}
+
+// TestImportWhileSkipped tests that an archive that is imported while
+// bootstrapping is skipped is either chosen before it was skipped, or
+// rejected.
+func TestImportWhileSkipped(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), archiveName)
+	writeTestArchive(t, archive, map[string][]byte{consensus.DatabaseFilename: []byte("consensus database")})
+	for i := 0; i < 100; i++ {
+		setupTest(t)
+		setStatus("")
+		var err error
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			err = Import(archive)
+		}()
+		go func() {
+			defer wg.Done()
+			Skip()
+		}()
+		wg.Wait()
+		if err != nil && !errors.Is(err, errBootstrapStarted) {
+			t.Fatal(err)
+		}
+		if chosen := err == nil; Importing() != chosen {
+			t.Fatalf("expected the archive to be chosen: %v, got %v", chosen, Importing())
+		}
+		if got := currentStatus(); got != Skipped {
+			t.Fatalf("expected status %q, got %q", Skipped, got)
+		}
+		imported.Lock()
+		imported.path = ""
+		imported.Unlock()
+	}
+}
@@ -0,0 +1,152 @@
+package bootstrapper
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"gitlab.com/scpcorp/webwallet/build"
+)
+
+// maxManifestSize is the largest manifest that is read.
+const maxManifestSize = 1 << 16
+
+var (
+	// errBadSignature is returned when the manifest's signature does not
+	// verify against the pinned key.
+	errBadSignature = errors.New("the manifest's signature is invalid")
+	// errChecksumMismatch is returned when the archive's SHA-256 digest does
+	// not match the manifest.
+	errChecksumMismatch = errors.New("the archive's SHA-256 checksum does not match the manifest")
+)
+
+// manifest describes a consensus database archive so that it can be verified
+// before it is installed.
+type manifest struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	// DatabaseSize is the size of the extracted consensus database. It is
+	// optional and only used to check the free disk space before the
+	// archive is downloaded.
+	DatabaseSize int64 `json:"database_size,omitempty"`
+	// Signature is the hex encoded Ed25519 signature of the archive's SHA-256
+	// digest. It is required when a signing key is pinned.
+	Signature string `json:"signature,omitempty"`
+}
+
+// signingKey returns the pinned key that signs the manifests, or nil when
+// none is pinned.
+func signingKey() (ed25519.PublicKey, error) {
+	if build.ConsensusSigningKey == "" {
+		return nil, nil
+	}
+	key, err := hex.DecodeString(build.ConsensusSigningKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("the pinned consensus signing key is not a hex encoded Ed25519 public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// requestManifest fetches the manifest at url and checks it. When key is not
+// nil the manifest must be signed by it.
+func requestManifest(url string, key ed25519.PublicKey) (manifest, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return manifest{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return manifest{}, fmt.Errorf("unexpected response %q", resp.Status)
+	}
+	var m manifest
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&m)
+	if err != nil {
+		return manifest{}, fmt.Errorf("unable to decode the manifest: %w", err)
+	}
+	return m, m.check(key)
+}
+
+// check checks that the manifest is complete and, when key is not nil, that
+// it is signed by key.
+func (m manifest) check(key ed25519.PublicKey) error {
+	if m.Size <= 0 {
+		return errors.New("the manifest has no size")
+	}
+	digest, err := m.digest()
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		if m.Signature != "" {
+			log.Info("Bootstrapper cannot verify the manifest's signature because no signing key is pinned")
+		}
+		return nil
+	}
+	signature, err := hex.DecodeString(m.Signature)
+	if err != nil || !ed25519.Verify(key, digest, signature) {
+		return errBadSignature
+	}
+	return nil
+}
+
+// spaceNeeded returns the disk space needed to download the rest of the
+// archive, of which partial bytes have been downloaded, and to extract it.
+// The extracted database is assumed to be at least as large as the consensus
+// size byte check when the manifest does not tell its size.
+func (m manifest) spaceNeeded(partial int64) int64 {
+	database := m.DatabaseSize
+	if database <= 0 {
+		database = build.ConsensusSizeByteCheck()
+	}
+	remaining := m.Size - partial
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining + database
+}
+
+// digest returns the SHA-256 digest of the archive the manifest describes.
+func (m manifest) digest() ([]byte, error) {
+	digest, err := hex.DecodeString(m.SHA256)
+	if err != nil || len(digest) != sha256.Size {
+		return nil, errors.New("the manifest has no valid SHA-256 checksum")
+	}
+	return digest, nil
+}
+
+// verifyArchive checks the size and the SHA-256 digest of the archive at
+// path against the manifest.
+func verifyArchive(path string, m manifest) error {
+	digest, err := m.digest()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() != m.Size {
+		return fmt.Errorf("the archive is %d bytes but the manifest expects %d", fi.Size(), m.Size)
+	}
+	telemetry.begin(StageVerifying, 0, fi.Size())
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(h, telemetryWriter{}), f); err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Sum(nil), digest) {
+		return errChecksumMismatch
+	}
+	return nil
+}
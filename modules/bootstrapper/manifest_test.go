@@ -0,0 +1,166 @@
+package bootstrapper
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/NebulousLabs/fastrand"
+)
+
+// newTestKey returns a new Ed25519 key pair.
+func newTestKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	public, private, err := ed25519.GenerateKey(fastrand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return public, private
+}
+
+// newTestManifest returns the manifest of the archive, signed by key unless
+// key is nil.
+func newTestManifest(archive []byte, key ed25519.PrivateKey) manifest {
+	digest := sha256.Sum256(archive)
+	m := manifest{
+		Size:   int64(len(archive)),
+		SHA256: hex.EncodeToString(digest[:]),
+	}
+	if key != nil {
+		m.Signature = hex.EncodeToString(ed25519.Sign(key, digest[:]))
+	}
+	return m
+}
+
+// newTestMirror starts a mirror that serves the archive and the manifest.
+func newTestMirror(t *testing.T, archive []byte, m manifest) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/"+archiveName, func(w http.ResponseWriter, req *http.Request) {
+		w.Write(archive)
+	})
+	mux.HandleFunc("/"+manifestName, func(w http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(w).Encode(m)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestManifestVerification tests that an archive is only accepted when it
+// matches its manifest, and the manifest is signed by the pinned key.
+func TestManifestVerification(t *testing.T) {
+	public, private := newTestKey(t)
+	_, otherPrivate := newTestKey(t)
+	tests := []struct {
+		name string
+		// served is the archive the mirror serves, and archive is the one
+		// its manifest describes.
+		served   []byte
+		archive  []byte
+		signer   ed25519.PrivateKey
+		pinned   ed25519.PublicKey
+		wantErr  error
+		anyError bool
+	}{
+		{
+			name:    "good",
+			served:  testArchive,
+			archive: testArchive,
+		},
+		{
+			name:    "good signed",
+			served:  testArchive,
+			archive: testArchive,
+			signer:  private,
+			pinned:  public,
+		},
+		{
+			name:     "truncated",
+			served:   testArchive[:len(testArchive)/2],
+			archive:  testArchive,
+			signer:   private,
+			pinned:   public,
+			anyError: true,
+		},
+		{
+			name:    "wrong hash",
+			served:  append([]byte("tampered "), testArchive[9:]...),
+			archive: testArchive,
+			signer:  private,
+			pinned:  public,
+			wantErr: errChecksumMismatch,
+		},
+		{
+			name:    "unsigned",
+			served:  testArchive,
+			archive: testArchive,
+			pinned:  public,
+			wantErr: errBadSignature,
+		},
+		{
+			name:    "bad signature",
+			served:  testArchive,
+			archive: testArchive,
+			signer:  otherPrivate,
+			pinned:  public,
+			wantErr: errBadSignature,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupTest(t)
+			mirror := newTestMirror(t, test.served, newTestManifest(test.archive, test.signer))
+			target := filepath.Join(t.TempDir(), partialFileName)
+
+			m, err := requestManifest(mirrorURL(mirror.URL, manifestName), test.pinned)
+			if err == nil {
+				err = download(mirrorURL(mirror.URL, archiveName), target, m.Size)
+			}
+			if err == nil {
+				err = verifyArchive(target, m)
+			}
+			switch {
+			case test.wantErr != nil && !errors.Is(err, test.wantErr):
+				t.Fatalf("expected %v, got %v", test.wantErr, err)
+			case test.anyError && err == nil:
+				t.Fatal("expected an error")
+			case test.wantErr == nil && !test.anyError && err != nil:
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// TestManifestCheck tests that incomplete manifests are rejected.
+func TestManifestCheck(t *testing.T) {
+	good := newTestManifest(testArchive, nil)
+	tests := []struct {
+		name    string
+		modify  func(m *manifest)
+		wantErr bool
+	}{
+		{name: "complete", modify: func(m *manifest) {}},
+		{name: "no size", modify: func(m *manifest) { m.Size = 0 }, wantErr: true},
+		{name: "no checksum", modify: func(m *manifest) { m.SHA256 = "" }, wantErr: true},
+		{name: "short checksum", modify: func(m *manifest) { m.SHA256 = m.SHA256[:32] }, wantErr: true},
+		{name: "checksum not hex", modify: func(m *manifest) { m.SHA256 = "zz" + m.SHA256[2:] }, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupTest(t)
+			m := good
+			test.modify(&m)
+			err := m.check(nil)
+			if test.wantErr && err == nil {
+				t.Fatal("expected an error")
+			} else if !test.wantErr && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
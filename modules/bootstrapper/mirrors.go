@@ -0,0 +1,104 @@
+package bootstrapper
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gitlab.com/scpcorp/webwallet/logger"
+)
+
+// DefaultMirror is the mirror the consensus set is bootstrapped from when no
+// mirrors are configured.
+const DefaultMirror = "https://consensus.scpri.me/releases"
+
+// Names of the archive and its manifest on the mirrors.
+const (
+	archiveName  = "consensus-latest.zip"
+	manifestName = "consensus-latest.json"
+)
+
+// mirrors are the base URLs the archive is downloaded from, in the order they
+// are tried.
+var mirrors = []string{DefaultMirror}
+
+// SetMirrors sets the base URLs of the mirrors the consensus set is
+// bootstrapped from, in the order they are tried. The default mirror is used
+// when urls is empty.
+func SetMirrors(urls []string) {
+	if len(urls) == 0 {
+		urls = []string{DefaultMirror}
+	}
+	mirrors = append([]string(nil), urls...)
+}
+
+// Mirrors returns the base URLs of the mirrors in the order they are tried.
+func Mirrors() []string {
+	return append([]string(nil), mirrors...)
+}
+
+// fileSize returns the size of the file at path, or 0 if it does not exist.
+func fileSize(path string) int64 {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+// mirrorURL returns the URL of the named file on the mirror.
+func mirrorURL(mirror string, name string) string {
+	return strings.TrimSuffix(mirror, "/") + "/" + name
+}
+
+// fetch downloads the archive to target from the first mirror that serves a
+// valid manifest and the archive, and returns the manifest. A mirror that
+// fails is failed over to the next one. When every mirror has failed the
+// download is paused until the user resumes it, and the mirrors are tried
+// again. fetch returns errStopped when the bootstrapper is skipped or closed.
+func fetch(target string, key ed25519.PublicKey) (manifest, error) {
+	var current manifest
+	for {
+		var failures []string
+		for _, mirror := range Mirrors() {
+			mirrorLog := log.With(logger.F("mirror", mirror))
+			telemetry.setMirror(mirror)
+			m, err := requestManifest(mirrorURL(mirror, manifestName), key)
+			if err != nil {
+				mirrorLog.Warn("Bootstrapper failed to obtain the manifest", logger.Err(err))
+				failures = append(failures, fmt.Sprintf("%s: %v", mirror, err))
+				continue
+			}
+			if current.SHA256 != "" && m.SHA256 != current.SHA256 {
+				// The mirror serves another archive than the one that was
+				// partially downloaded.
+				removePartial(target)
+			}
+			current = m
+			telemetry.begin(StageDownloading, fileSize(target), m.Size)
+			// Make sure the archive and the database fit before downloading.
+			err = waitForSpace(filepath.Dir(target), m.spaceNeeded(fileSize(target)))
+			if err != nil {
+				return manifest{}, err
+			}
+			err = download(mirrorURL(mirror, archiveName), target, m.Size)
+			if err == nil {
+				return m, nil
+			}
+			if errors.Is(err, errStopped) {
+				return manifest{}, err
+			}
+			mirrorLog.Warn("Bootstrapper failed to download the consensus set", logger.Err(err))
+			failures = append(failures, fmt.Sprintf("%s: %v", mirror, err))
+		}
+		err := fmt.Errorf("every mirror failed: %s", strings.Join(failures, "; "))
+		log.Error("Bootstrapper paused the download", logger.Err(err))
+		pauseWithError(err)
+		if err := waitWhilePaused(); err != nil {
+			return manifest{}, err
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package bootstrapper
+
+import (
+	"sync"
+	"time"
+)
+
+// Stages of the bootstrapper as they are reported by its telemetry.
+const (
+	StageWaiting       = "waiting"
+	StageDownloading   = "downloading"
+	StageVerifying     = "verifying"
+	StageDecompressing = "decompressing"
+	StageDone          = "done"
+	StageSkipped       = "skipped"
+	StageClosed        = "closed"
+	StageFailed        = "failed"
+)
+
+// rateWindow is the period the throughput is averaged over.
+const rateWindow = 10 * time.Second
+
+// sampleInterval is the minimum time between two throughput samples.
+const sampleInterval = 250 * time.Millisecond
+
+// Telemetry is the progress of the bootstrapper's current stage. While the
+// archive is downloaded the bytes are the bytes of the archive on disk, while
+// it is verified the bytes that have been checked and while it is
+// decompressed the bytes of the database that have been written.
+type Telemetry struct {
+	// Progress is the bootstrapper's progress as returned by Progress.
+	Progress string `json:"progress"`
+	Stage    string `json:"stage"`
+	// Mirror is the mirror the archive is downloaded from.
+	Mirror     string `json:"mirror,omitempty"`
+	BytesDone  int64  `json:"bytes_done"`
+	BytesTotal int64  `json:"bytes_total"`
+	// BytesPerSecond is the throughput averaged over the last ten seconds.
+	BytesPerSecond float64 `json:"bytes_per_second"`
+	// ETASeconds is the estimated time until the stage is finished. It is -1
+	// when it cannot be estimated.
+	ETASeconds float64 `json:"eta_seconds"`
+	Paused     bool    `json:"paused"`
+	// Error is the error that paused the bootstrapper.
+	Error string `json:"error,omitempty"`
+}
+
+// sample is the number of bytes done at a point in time.
+type sample struct {
+	at   time.Time
+	done int64
+}
+
+// tracker tracks the progress of the bootstrapper's current stage.
+type tracker struct {
+	mu      sync.Mutex
+	stage   string
+	mirror  string
+	done    int64
+	total   int64
+	samples []sample
+}
+
+// telemetry is the progress of the bootstrapper.
+var telemetry tracker
+
+// GetTelemetry returns the progress of the bootstrapper's current stage.
+func GetTelemetry() Telemetry {
+	t := telemetry.snapshot(time.Now())
+	t.Progress = Progress()
+	if err := DownloadError(); err != nil {
+		t.Error = err.Error()
+	}
+	t.Paused = Paused()
+	switch t.Progress {
+	case Skipped:
+		t.Stage = StageSkipped
+	case Closed:
+		t.Stage = StageClosed
+	case Failed:
+		t.Stage = StageFailed
+	}
+	if t.Paused || t.Stage == StageSkipped || t.Stage == StageClosed || t.Stage == StageFailed {
+		t.BytesPerSecond = 0
+		t.ETASeconds = -1
+	}
+	return t
+}
+
+// begin starts tracking a stage of which done of total bytes are done.
+func (t *tracker) begin(stage string, done int64, total int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stage = stage
+	t.done = done
+	t.total = total
+	t.samples = []sample{{at: time.Now(), done: done}}
+}
+
+// finish records that the bootstrapper is done, keeping the bytes of the last
+// stage.
+func (t *tracker) finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stage = StageDone
+	t.samples = nil
+}
+
+// setMirror records the mirror the archive is downloaded from.
+func (t *tracker) setMirror(mirror string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mirror = mirror
+}
+
+// add records that n more bytes are done.
+func (t *tracker) add(n int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.done += n
+	now := time.Now()
+	if len(t.samples) == 0 || now.Sub(t.samples[len(t.samples)-1].at) >= sampleInterval {
+		t.samples = append(t.samples, sample{at: now, done: t.done})
+		t.prune(now)
+	}
+}
+
+// prune drops the samples that are older than the rate window, keeping the
+// newest of them as the start of the window.
+func (t *tracker) prune(now time.Time) {
+	i := 0
+	for i+1 < len(t.samples) && now.Sub(t.samples[i+1].at) >= rateWindow {
+		i++
+	}
+	t.samples = t.samples[i:]
+}
+
+// snapshot returns the progress of the stage at now.
+func (t *tracker) snapshot(now time.Time) Telemetry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tel := Telemetry{
+		Stage:      t.stage,
+		Mirror:     t.mirror,
+		BytesDone:  t.done,
+		BytesTotal: t.total,
+		ETASeconds: -1,
+	}
+	if tel.Stage == "" {
+		tel.Stage = StageWaiting
+	}
+	t.prune(now)
+	if len(t.samples) > 0 {
+		oldest := t.samples[0]
+		if elapsed := now.Sub(oldest.at).Seconds(); elapsed > 0 {
+			tel.BytesPerSecond = float64(t.done-oldest.done) / elapsed
+		}
+	}
+	if tel.BytesPerSecond > 0 && t.total >= t.done {
+		tel.ETASeconds = float64(t.total-t.done) / tel.BytesPerSecond
+	}
+	return tel
+}
+
+// percent returns the percentage of the stage that is done.
+func (t *tracker) percent() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.total <= 0 {
+		return 0
+	}
+	return int(float64(t.done) / float64(t.total) * 100)
+}
+
+// telemetryWriter counts the bytes written to it as done.
+type telemetryWriter struct{}
+
+// Write implements io.Writer.
+func (telemetryWriter) Write(p []byte) (int, error) {
+	telemetry.add(int64(len(p)))
+	return len(p), nil
+}
@@ -2,10 +2,11 @@ package browserconfig
 
 import (
 	"errors"
-	"fmt"
 	"os"
 	"path/filepath"
 	"time"
+
+	"gitlab.com/scpcorp/webwallet/logger"
 )
 
 // BrowserConfigDir defined the directory that the browser config is stored in
@@ -28,9 +29,24 @@ const Initialized = "Initialized"
 
 var status = ""
 
+// log is the logger the browser config writes its messages to.
+var log = logger.New(os.Stdout, logger.LevelInfo)
+
+// SetLogger sets the logger the browser config writes its messages to.
+func SetLogger(l logger.Logger) {
+	log = l
+}
+
+// override is the browser that was set for this run, if any.
+var override = ""
+
+// skipped is true when the browser is not configured because the GUI is not
+// launched.
+var skipped = false
+
 // Close the consensus builder module
 func Close() {
-	fmt.Println("Closing browser config...")
+	log.Info("Closing browser config")
 	status = Closed
 }
 
@@ -66,8 +82,29 @@ func Configure(dataDir string, browser string) error {
 	return nil
 }
 
+// Override sets the browser for this run without asking the user and without
+// changing the stored browser config.
+func Override(browser string) {
+	override = browser
+	status = Done
+}
+
+// Skip skips the browser configuration because the GUI is not launched.
+func Skip() {
+	skipped = true
+	status = Done
+}
+
+// Skipped returns true when the browser configuration was skipped.
+func Skipped() bool {
+	return skipped
+}
+
 // Browser returns the configured browser
 func Browser(dataDir string) (string, error) {
+	if override != "" {
+		return override, nil
+	}
 	browserConfig := filepath.Join(dataDir, BrowserConfigDir, BrowserConfigDir+".txt")
 	browser, err := os.ReadFile(browserConfig)
 	if err != nil {
@@ -83,6 +120,10 @@ func Status() string {
 
 // Start begins the process of buildinng the consensus set from peers.
 func Start(dataDir string) {
+	if override != "" || skipped {
+		status = Done
+		return
+	}
 	browserConfig := filepath.Join(dataDir, BrowserConfigDir, BrowserConfigDir+".txt")
 	if exists(browserConfig) {
 		status = Done
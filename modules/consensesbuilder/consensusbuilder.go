@@ -12,6 +12,7 @@ import (
 	"gitlab.com/scpcorp/ScPrime/modules/consensus"
 
 	"gitlab.com/scpcorp/webwallet/build"
+	"gitlab.com/scpcorp/webwallet/logger"
 )
 
 // Closed is the value that the consensus builder's progress is set to after it has been closed.
@@ -22,9 +23,17 @@ var LocalConsensusSize = int64(0)
 
 var status = ""
 
+// log is the logger the consensus builder writes its messages to.
+var log = logger.New(os.Stdout, logger.LevelInfo)
+
+// SetLogger sets the logger the consensus builder writes its messages to.
+func SetLogger(l logger.Logger) {
+	log = l
+}
+
 // Close the consensus builder module
 func Close() {
-	fmt.Println("Closing consensusset builder...")
+	log.Info("Closing consensus set builder")
 	status = Closed
 }
 
@@ -80,7 +89,7 @@ func updateStatus(filepath string, size int64) int {
 	}
 	_, err := strconv.Atoi(status)
 	if err != nil {
-		fmt.Printf("%v", err)
+		log.Warn("Consensus builder status is not a number", logger.Err(err))
 		return 0
 	}
 	fi, err := os.Stat(filepath)
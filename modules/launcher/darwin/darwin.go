@@ -6,61 +6,61 @@ import (
 	"github.com/pkg/browser"
 )
 
-// Launch launches the browser
-func Launch(browserCfg string) bool {
-	if launch(browserCfg) {
+// Launch launches the browser at the URL
+func Launch(browserCfg string, url string) bool {
+	if launch(browserCfg, url) {
 		return true
 	}
-	return fallback(browserCfg)
+	return fallback(browserCfg, url)
 }
 
-func launch(browserCfg string) bool {
+func launch(browserCfg string, url string) bool {
 	switch browserCfg {
 	case "edge":
-		return edge()
+		return edge(url)
 	case "chrome":
-		return chrome()
+		return chrome(url)
 	case "firefox":
-		return firefox()
+		return firefox(url)
 	case "safari":
-		return safari()
+		return safari(url)
 	}
 	return false
 }
 
-func fallback(browserCfg string) bool {
-	if browserCfg != "chrome" && chrome() {
+func fallback(browserCfg string, url string) bool {
+	if browserCfg != "chrome" && chrome(url) {
 		return true
 	}
-	if browserCfg != "edge" && edge() {
+	if browserCfg != "edge" && edge(url) {
 		return true
 	}
-	if browserCfg != "firefox" && firefox() {
+	if browserCfg != "firefox" && firefox(url) {
 		return true
 	}
-	if browserCfg != "safari" && safari() {
+	if browserCfg != "safari" && safari(url) {
 		return true
 	}
-	err := browser.OpenURL("http://localhost:4300")
+	err := browser.OpenURL(url)
 	return err == nil
 }
 
-func edge() bool {
-	err := exec.Command("open", "-n", "-a", "Microsoft Edge", "--args", "--app=http://localhost:4300").Run()
+func edge(url string) bool {
+	err := exec.Command("open", "-n", "-a", "Microsoft Edge", "--args", "--app="+url).Run()
 	return err == nil
 }
 
-func chrome() bool {
-	err := exec.Command("open", "-n", "-a", "Google Chrome", "--args", "--app=http://localhost:4300").Run()
+func chrome(url string) bool {
+	err := exec.Command("open", "-n", "-a", "Google Chrome", "--args", "--app="+url).Run()
 	return err == nil
 }
 
-func firefox() bool {
-	err := exec.Command("open", "-n", "-a", "Firefox", "--args", "-new-window=http://localhost:4300").Run()
+func firefox(url string) bool {
+	err := exec.Command("open", "-n", "-a", "Firefox", "--args", "-new-window="+url).Run()
 	return err == nil
 }
 
-func safari() bool {
-	err := exec.Command("open", "-a", "Safari", "http://localhost:4300").Run()
+func safari(url string) bool {
+	err := exec.Command("open", "-a", "Safari", url).Run()
 	return err == nil
 }
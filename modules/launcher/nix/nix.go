@@ -6,100 +6,100 @@ import (
 	"github.com/pkg/browser"
 )
 
-// Launch launches the browser
-func Launch(browserCfg string) bool {
-	if launch(browserCfg) {
+// Launch launches the browser at the URL
+func Launch(browserCfg string, url string) bool {
+	if launch(browserCfg, url) {
 		return true
 	}
-	return fallback(browserCfg)
+	return fallback(browserCfg, url)
 }
 
-func launch(browserCfg string) bool {
+func launch(browserCfg string, url string) bool {
 	switch browserCfg {
 	case "edge":
-		return edge()
+		return edge(url)
 	case "chrome":
-		return chrome()
+		return chrome(url)
 	case "firefox":
-		return firefox()
+		return firefox(url)
 	}
 	return false
 }
 
-func fallback(browserCfg string) bool {
-	if browserCfg != "edge" && edge() {
+func fallback(browserCfg string, url string) bool {
+	if browserCfg != "edge" && edge(url) {
 		return true
 	}
-	if browserCfg != "chrome" && chrome() {
+	if browserCfg != "chrome" && chrome(url) {
 		return true
 	}
-	if browserCfg != "chromium" && chromium() {
+	if browserCfg != "chromium" && chromium(url) {
 		return true
 	}
-	if browserCfg != "firefox" && firefox() {
+	if browserCfg != "firefox" && firefox(url) {
 		return true
 	}
-	err := browser.OpenURL("http://localhost:4300")
+	err := browser.OpenURL(url)
 	return err == nil
 }
 
-func edge() bool {
-	err := exec.Command("microsoft-edge", "--app=http://localhost:4300").Run()
+func edge(url string) bool {
+	err := exec.Command("microsoft-edge", "--app="+url).Run()
 	if err == nil {
 		return true
 	}
-	err = exec.Command("microsoft-edge-stable", "--app=http://localhost:4300").Run()
+	err = exec.Command("microsoft-edge-stable", "--app="+url).Run()
 	if err == nil {
 		return true
 	}
-	err = exec.Command("/usr/bin/microsoft-edge", "--app=http://localhost:4300").Run()
+	err = exec.Command("/usr/bin/microsoft-edge", "--app="+url).Run()
 	if err == nil {
 		return true
 	}
-	err = exec.Command("/usr/bin/microsoft-edge-stable", "--app=http://localhost:4300").Run()
+	err = exec.Command("/usr/bin/microsoft-edge-stable", "--app="+url).Run()
 	return err == nil
 }
 
-func chrome() bool {
-	err := exec.Command("google-chrome", "--app=http://localhost:4300").Run()
+func chrome(url string) bool {
+	err := exec.Command("google-chrome", "--app="+url).Run()
 	if err == nil {
 		return true
 	}
-	err = exec.Command("google-chrome-stable", "--app=http://localhost:4300").Run()
+	err = exec.Command("google-chrome-stable", "--app="+url).Run()
 	if err == nil {
 		return true
 	}
-	err = exec.Command("/usr/bin/google-chrome", "--app=http://localhost:4300").Run()
+	err = exec.Command("/usr/bin/google-chrome", "--app="+url).Run()
 	if err == nil {
 		return true
 	}
-	err = exec.Command("/usr/bin/google-chrome-stable", "--app=http://localhost:4300").Run()
+	err = exec.Command("/usr/bin/google-chrome-stable", "--app="+url).Run()
 	return err == nil
 }
 
-func chromium() bool {
-	err := exec.Command("chromium", "--app=http://localhost:4300").Run()
+func chromium(url string) bool {
+	err := exec.Command("chromium", "--app="+url).Run()
 	if err == nil {
 		return true
 	}
-	err = exec.Command("chromium-browser", "--app=http://localhost:4300").Run()
+	err = exec.Command("chromium-browser", "--app="+url).Run()
 	if err == nil {
 		return true
 	}
-	err = exec.Command("/usr/bin/chromium", "--app=http://localhost:4300").Run()
+	err = exec.Command("/usr/bin/chromium", "--app="+url).Run()
 	if err == nil {
 		return true
 	}
-	err = exec.Command("/usr/bin/chromium-browser", "--app=http://localhost:4300").Run()
+	err = exec.Command("/usr/bin/chromium-browser", "--app="+url).Run()
 	return err == nil
 }
 
-func firefox() bool {
-	err := exec.Command("firefox", "--new-window", "http://localhost:4300").Run()
+func firefox(url string) bool {
+	err := exec.Command("firefox", "--new-window", url).Run()
 	if err == nil {
 		return true
 	}
-	err = exec.Command("/usr/bin/firefox", "--new-window", "http://localhost:4300").Run()
+	err = exec.Command("/usr/bin/firefox", "--new-window", url).Run()
 	if err == nil {
 		return true
 	}
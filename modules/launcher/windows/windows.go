@@ -6,63 +6,63 @@ import (
 	"github.com/pkg/browser"
 )
 
-// Launch launches the browser
-func Launch(browserCfg string) bool {
-	if launch(browserCfg) {
+// Launch launches the browser at the URL
+func Launch(browserCfg string, url string) bool {
+	if launch(browserCfg, url) {
 		return true
 	}
-	return fallback(browserCfg)
+	return fallback(browserCfg, url)
 }
 
-func launch(browserCfg string) bool {
+func launch(browserCfg string, url string) bool {
 	switch browserCfg {
 	case "edge":
-		return edge()
+		return edge(url)
 	case "chrome":
-		return chrome()
+		return chrome(url)
 	case "firefox":
-		return firefox()
+		return firefox(url)
 	}
 	return false
 }
 
-func fallback(browserCfg string) bool {
-	if browserCfg != "edge" && edge() {
+func fallback(browserCfg string, url string) bool {
+	if browserCfg != "edge" && edge(url) {
 		return true
 	}
-	if browserCfg != "chrome" && chrome() {
+	if browserCfg != "chrome" && chrome(url) {
 		return true
 	}
-	if browserCfg != "firefox" && firefox() {
+	if browserCfg != "firefox" && firefox(url) {
 		return true
 	}
-	err := browser.OpenURL("http://localhost:4300")
+	err := browser.OpenURL(url)
 	return err == nil
 }
 
-func edge() bool {
-	err := exec.Command("C:/Program Files/Microsoft/Edge/Application/msedge.exe", "--app=http://localhost:4300").Run()
+func edge(url string) bool {
+	err := exec.Command("C:/Program Files/Microsoft/Edge/Application/msedge.exe", "--app="+url).Run()
 	if err == nil {
 		return true
 	}
-	err = exec.Command("C:/Program Files (x86)/Microsoft/Edge/Application/msedge.exe", "--app=http://localhost:4300").Run()
+	err = exec.Command("C:/Program Files (x86)/Microsoft/Edge/Application/msedge.exe", "--app="+url).Run()
 	return err == nil
 }
 
-func chrome() bool {
-	err := exec.Command("C:/Program Files/Google/Chrome/Application/chrome.exe", "--app=http://localhost:4300").Run()
+func chrome(url string) bool {
+	err := exec.Command("C:/Program Files/Google/Chrome/Application/chrome.exe", "--app="+url).Run()
 	if err == nil {
 		return true
 	}
-	err = exec.Command("C:/Program Files (x86)/Google/Chrome/Application/chrome.exe", "--app=http://localhost:4300").Run()
+	err = exec.Command("C:/Program Files (x86)/Google/Chrome/Application/chrome.exe", "--app="+url).Run()
 	return err == nil
 }
 
-func firefox() bool {
-	err := exec.Command("C:/Program Files/Mozilla Firefox/firefox.exe", "-new-window", "http://localhost:4300").Run()
+func firefox(url string) bool {
+	err := exec.Command("C:/Program Files/Mozilla Firefox/firefox.exe", "-new-window", url).Run()
 	if err == nil {
 		return true
 	}
-	err = exec.Command("C:/Program Files (x86)/Mozilla Firefox/firefox.exe", "-new-window", "http://localhost:4300").Run()
+	err = exec.Command("C:/Program Files (x86)/Mozilla Firefox/firefox.exe", "-new-window", url).Run()
 	return err == nil
 }
@@ -1,37 +1,35 @@
-function refreshBlockHeight(sessionID) {
+function updateBlockHeight(blockHeight, status, color) {
+  // Automatically refresh form to make GUI smoother.
+  if (status === "Synchronized") {
+    var refreshForm = document.getElementById("refreshForm")
+    if (typeof(refreshForm) != 'undefined' && refreshForm != null) {
+      refreshForm.submit()
+    }
+  }
+  for (const element of document.getElementsByClassName("block_height")){
+    element.innerHTML=blockHeight;
+  }
+  for (const element of document.getElementsByClassName("status")){
+    element.innerHTML=status;
+  }
+  for (const element of document.getElementsByClassName("status")){
+    element.className="status " + color
+  }
+}
+function refreshBlockHeight() {
   if (document.getElementsByClassName('block_height').length > 0) {
-    var data = new FormData();
-    data.append("session_id", sessionID)
-    fetch("/gui/blockHeight", {method: "POST", body: data})
+    fetch("/gui/blockHeight", {method: "POST"})
       .then(response => response.json())
       .then(result => {
-        var blockHeight = result[0]
-        var status = result[1]
-        var color = result[2]
-        // Automatically refresh form to make GUI smoother.
-        if (status === "Synchronized") {
-          var refreshForm = document.getElementById("refreshForm")
-          if (typeof(refreshForm) != 'undefined' && refreshForm != null) {
-            refreshForm.submit()
-          }
-        }
-        for (const element of document.getElementsByClassName("block_height")){
-          element.innerHTML=blockHeight;
-        }
-        for (const element of document.getElementsByClassName("status")){
-          element.innerHTML=status;
-        }
-        for (const element of document.getElementsByClassName("status")){
-          element.className="status " + color
-        }
-        setTimeout(() => {refreshBlockHeight(sessionID);}, 1000);
+        updateBlockHeight(result[0], result[1], result[2])
+        setTimeout(() => {refreshBlockHeight();}, 1000);
       })
       .catch(error => {
         console.error("Error:", error);
-        setTimeout(() => {refreshBlockHeight(sessionID);}, 1000);
+        setTimeout(() => {refreshBlockHeight();}, 1000);
       })
   } else {
-    setTimeout(() => {refreshBlockHeight(sessionID);}, 50);
+    setTimeout(() => {refreshBlockHeight();}, 50);
   }
 }
 function isLastPage() {
@@ -41,53 +39,68 @@ function isLastPage() {
   }
   return false;
 }
-function refreshBalance(sessionID) {
+function updateBalance(confirmed, unconfirmed, spfFunds, whale) {
+  for (const element of document.getElementsByClassName("confirmed")){
+    element.innerHTML = confirmed;
+  }
+  for (const element of document.getElementsByClassName("unconfirmed")){
+    if (isLastPage() && element.innerHTML.trim() !== unconfirmed.trim()) {
+      var refreshTransactions = document.getElementById("refresh_transactions")
+      if (typeof(refreshTransactions) != 'undefined' && refreshTransactions != null) {
+        refreshTransactions.submit()
+      }
+    }
+    element.innerHTML = unconfirmed;
+  }
+  for (const element of document.getElementsByClassName("spf_funds")){
+    element.innerHTML = spfFunds;
+  }
+  var whaleSize = document.getElementById("whale_size")
+  if (typeof(whaleSize) != 'undefined' && whaleSize != null) {
+    whaleSize.innerHTML = "Whale Size: " + whale;
+  }
+  var whaleSizeButton = document.getElementById("whale_size_button")
+  if (typeof(whaleSizeButton) != 'undefined' && whaleSizeButton != null) {
+    whaleSizeButton.value = "Whale Size: " + whale;
+  }
+}
+function refreshBalance() {
   var balance = document.getElementById("balance");
   if (typeof(balance) != 'undefined' && balance != null) {
-    var data = new FormData();
-    data.append("session_id", sessionID)
-    fetch("/gui/balance", {method: "POST", body: data})
+    fetch("/gui/balance", {method: "POST"})
       .then(response => response.json())
       .then(result => {
-        for (const element of document.getElementsByClassName("confirmed")){
-          element.innerHTML = result[0];
-        }
-        for (const element of document.getElementsByClassName("unconfirmed")){
-          if (isLastPage() && element.innerHTML.trim() !== result[1].trim()) {
-            var refreshTransactions = document.getElementById("refresh_transactions")
-            if (typeof(refreshTransactions) != 'undefined' && refreshTransactions != null) {
-              refreshTransactions.submit()
-            }
-          }
-          element.innerHTML = result[1];
-        }
-        for (const element of document.getElementsByClassName("spf_funds")){
-          element.innerHTML = result[2];
-        }
-        var whaleSize = document.getElementById("whale_size")
-        if (typeof(whaleSize) != 'undefined' && whaleSize != null) {
-          whaleSize.innerHTML = "Whale Size: " + result[4];
-        }
-        var whaleSizeButton = document.getElementById("whale_size_button")
-        if (typeof(whaleSizeButton) != 'undefined' && whaleSizeButton != null) {
-          whaleSizeButton.value = "Whale Size: " + result[4];
-        }
-        setTimeout(() => {refreshBalance(sessionID);}, 1000);
+        updateBalance(result[0], result[1], result[2], result[4])
+        setTimeout(() => {refreshBalance();}, 1000);
       })
       .catch(error => {
         console.error("Error:", error);
-        setTimeout(() => {refreshBalance(sessionID);}, 1000);
+        setTimeout(() => {refreshBalance();}, 1000);
       })
   } else {
-    setTimeout(() => {refreshBalance(sessionID);}, 50);
+    setTimeout(() => {refreshBalance();}, 50);
+  }
+}
+function listenForWalletEvents() {
+  // Fall back to polling when the browser does not support server-sent events.
+  if (typeof(EventSource) == 'undefined') {
+    refreshBlockHeight()
+    refreshBalance()
+    return
   }
+  var source = new EventSource("/gui/events")
+  source.addEventListener("wallet", event => {
+    var result = JSON.parse(event.data)
+    updateBlockHeight(result.height, result.status, result.status_color)
+    updateBalance(result.scp_balance, result.unconfirmed_delta, result.spf_balance, result.whale_size)
+  })
 }
 function refreshBootstrapperProgress() {
   if (document.getElementsByClassName('bootstrapper-progress').length > 0) {
     fetch("/gui/bootstrapperProgress")
       .then(response => response.json())
       .then(result => {
-        var status = result[0]
+        var status = result.progress
         // Autorefresh wallet to make onboarding smoother.
         if (status === "100%") {
           var refreshBootstrapper = document.getElementById("refreshBootstrapper")
@@ -98,6 +111,10 @@ function refreshBootstrapperProgress() {
         for (const element of document.getElementsByClassName("bootstrapper-progress")){
           element.innerHTML = status;
         }
+        for (const element of document.getElementsByClassName("bootstrapper-telemetry")){
+          element.textContent = formatBootstrapperTelemetry(result);
+        }
+        updateBootstrapperPaused(result.paused, result.error)
         setTimeout(() => {refreshBootstrapperProgress();}, 1000);
       })
       .catch(error => {
@@ -108,6 +125,55 @@ function refreshBootstrapperProgress() {
     setTimeout(() => {refreshBootstrapperProgress();}, 50);
   }
 }
+var bootstrapperStages = {
+  waiting: "Waiting",
+  downloading: "Downloading",
+  verifying: "Verifying",
+  decompressing: "Decompressing",
+  done: "Done",
+  skipped: "Skipped",
+  closed: "Closed",
+  failed: "Failed",
+}
+function formatBytes(bytes) {
+  var units = ["B", "KiB", "MiB", "GiB", "TiB"]
+  var i = 0
+  while (bytes >= 1024 && i < units.length - 1) {
+    bytes /= 1024
+    i++
+  }
+  return (i == 0 ? bytes : bytes.toFixed(1)) + " " + units[i]
+}
+function formatBootstrapperTelemetry(telemetry) {
+  var text = bootstrapperStages[telemetry.stage] || telemetry.stage
+  if (telemetry.bytes_total > 0) {
+    text += " " + formatBytes(telemetry.bytes_done) + " of " + formatBytes(telemetry.bytes_total)
+  }
+  if (telemetry.bytes_per_second > 0) {
+    text += " at " + formatBytes(telemetry.bytes_per_second) + "/s"
+  }
+  if (telemetry.eta_seconds >= 0) {
+    text += ", " + formatElapsed(telemetry.eta_seconds) + " left"
+  }
+  return text
+}
+function updateBootstrapperPaused(paused, error) {
+  var pausedElement = document.getElementById("bootstrapperPaused")
+  if (pausedElement != null) {
+    pausedElement.classList.toggle("display-none", !paused)
+  }
+  var pauseForm = document.getElementById("pauseBootstrapper")
+  if (pauseForm != null) {
+    pauseForm.classList.toggle("display-none", paused)
+  }
+  var resumeForm = document.getElementById("resumeBootstrapper")
+  if (resumeForm != null) {
+    resumeForm.classList.toggle("display-none", !paused)
+  }
+  for (const element of document.getElementsByClassName("bootstrapper-error")){
+    element.textContent = error ? ": " + error : "";
+  }
+}
 function refreshConsensusBuilderProgress() {
   if (document.getElementsByClassName('consensus-builder-progress').length > 0) {
     fetch("/gui/consensusBuilderProgress")
@@ -134,14 +200,54 @@ function refreshConsensusBuilderProgress() {
     setTimeout(() => {refreshConsensusBuilderProgress();}, 50);
   }
 }
-function refreshHeartbeat(sessionID) {
-  var data = new FormData();
-  data.append("session_id", sessionID)
-  fetch("/gui/heartbeat", {method: "POST", body: data})
+function formatElapsed(seconds) {
+  seconds = Math.round(seconds)
+  var h = Math.floor(seconds / 3600)
+  var m = Math.floor(seconds % 3600 / 60)
+  var s = seconds % 60
+  if (h > 0) {
+    return h + "h" + m + "m" + s + "s"
+  }
+  if (m > 0) {
+    return m + "m" + s + "s"
+  }
+  return s + "s"
+}
+function refreshStartupProgress() {
+  if (document.getElementsByClassName('startup-progress').length > 0) {
+    fetch("/gui/startupProgress")
+      .then(response => response.json())
+      .then(phases => {
+        for (const table of document.getElementsByClassName("startup-progress")) {
+          table.innerHTML = ""
+          for (const phase of phases) {
+            var row = table.insertRow()
+            row.className = "startup-phase " + phase.state
+            var elapsed = phase.state === "pending" ? "" : formatElapsed(phase.elapsed_seconds)
+            var cells = [[phase.title, "left"], [phase.state, "left"], [elapsed, "right"], [phase.progress || "", "right"]]
+            for (const [text, align] of cells) {
+              var cell = row.insertCell()
+              cell.className = align
+              cell.textContent = text
+            }
+          }
+        }
+        setTimeout(() => {refreshStartupProgress();}, 1000);
+      })
+      .catch(error => {
+        console.error("Error:", error);
+        setTimeout(() => {refreshStartupProgress();}, 1000);
+      })
+  } else {
+    setTimeout(() => {refreshStartupProgress();}, 50);
+  }
+}
+function refreshHeartbeat() {
+  fetch("/gui/heartbeat", {method: "POST"})
     .then(response => response.json())
     .then(result => {
     	if (result[0] === "true") {
-        setTimeout(() => {refreshHeartbeat(sessionID);}, 200);
+        setTimeout(() => {refreshHeartbeat();}, 200);
     	}
     })
     .catch(error => {
@@ -174,5 +280,6 @@ function copyToClipboard(textToCopy) {
 }
 refreshBootstrapperProgress()
 refreshConsensusBuilderProgress()
-refreshHeartbeat("")
+refreshStartupProgress()
+refreshHeartbeat()
 
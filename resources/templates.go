@@ -0,0 +1,91 @@
+package resources
+
+import (
+	"bytes"
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"regexp"
+)
+
+//go:embed resources/*.html resources/forms/*.html resources/transaction_templates/*.html
+var templateFS embed.FS
+
+// templatePatterns are the files in templateFS that hold templates.
+var templatePatterns = []string{
+	"resources/*.html",
+	"resources/forms/*.html",
+	"resources/transaction_templates/*.html",
+}
+
+// legacyPlaceholder matches the &PLACEHOLDER; markers that templates used
+// before they were moved to html/template.
+var legacyPlaceholder = regexp.MustCompile(`&[A-Z_]+;`)
+
+// templates holds every html template, named by its file name.
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"cacheBuster": cacheBuster,
+	"render":      render,
+}).ParseFS(templateFS, templatePatterns...))
+
+// Renderer is a view model that renders itself with its template.
+type Renderer interface {
+	Render(w io.Writer) error
+}
+
+// init checks every template against its view model so that a template that
+// refers to a missing field fails when the program starts rather than when the
+// page is first shown.
+func init() {
+	for _, pattern := range templatePatterns {
+		names, err := fs.Glob(templateFS, pattern)
+		if err != nil {
+			panic(err)
+		}
+		for _, name := range names {
+			b, err := templateFS.ReadFile(name)
+			if err != nil {
+				panic(err)
+			}
+			if placeholder := legacyPlaceholder.Find(b); placeholder != nil {
+				panic(fmt.Sprintf("template %s has unreplaced placeholder %s", name, placeholder))
+			}
+		}
+	}
+	for _, view := range views {
+		err := view.Render(ioutil.Discard)
+		if err != nil {
+			panic(fmt.Sprintf("template for %T is not valid: %v", view, err))
+		}
+	}
+}
+
+// execute renders the named template with the view model.
+func execute(w io.Writer, name string, data interface{}) error {
+	return templates.ExecuteTemplate(w, name, data)
+}
+
+// render renders a nested view model so that it can be placed in another
+// template. The output is already escaped by its own template.
+func render(view Renderer) (template.HTML, error) {
+	var buf bytes.Buffer
+	err := view.Render(&buf)
+	if err != nil {
+		return "", err
+	}
+	// #nosec G203 -- the HTML was produced by html/template.
+	return template.HTML(buf.String()), nil
+}
+
+// cacheBuster returns random data that is added to links so that the browser
+// does not serve them from its cache.
+func cacheBuster() string {
+	b := make([]byte, 16) //32 characters long
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,388 @@
+package resources
+
+import (
+	"io"
+)
+
+// Page is a view model of a page that shows the wallet header.
+type Page interface {
+	Renderer
+	// WithHeader returns a copy of the page that shows the header.
+	WithHeader(header Header) Page
+}
+
+// Header holds the wallet's name, status and balances that are shown at the
+// top of every wallet page.
+type Header struct {
+	SessionName      string
+	Status           string
+	StatusColor      string
+	BlockHeight      string
+	SCPBalance       string
+	UnconfirmedDelta string
+	SPFBalance       string
+	WhaleSize        string
+	MenuCollapsed    bool
+	CSRFToken        string
+	WebWalletVersion string
+	SPDVersion       string
+}
+
+// WalletPage is the main wallet page. The portal below the header shows the
+// transaction history, a transaction or the privacy policy.
+type WalletPage struct {
+	Header
+	Portal Renderer
+}
+
+// Render renders the wallet page.
+func (p WalletPage) Render(w io.Writer) error {
+	return execute(w, "wallet_template.html", p)
+}
+
+// WithHeader returns a copy of the page that shows the header.
+func (p WalletPage) WithHeader(header Header) Page {
+	p.Header = header
+	return p
+}
+
+// AlertPage is a wallet page with a popup that shows either a message or a
+// form.
+type AlertPage struct {
+	Header
+	Title string
+	// ShowStatus shows the wallet status in front of the title.
+	ShowStatus bool
+	Message    string
+	Form       Renderer
+	// Close adds a button that closes the popup.
+	Close bool
+}
+
+// Render renders the alert page.
+func (p AlertPage) Render(w io.Writer) error {
+	return execute(w, "alert_template.html", p)
+}
+
+// WithHeader returns a copy of the page that shows the header.
+func (p AlertPage) WithHeader(header Header) Page {
+	p.Header = header
+	return p
+}
+
+// ErrorPage shows an error without the wallet header.
+type ErrorPage struct {
+	Title     string
+	Message   string
+	CSRFToken string
+}
+
+// Render renders the error page.
+func (p ErrorPage) Render(w io.Writer) error {
+	return execute(w, "error_template.html", p)
+}
+
+// StartupPhase is a step of loading the node as it is shown on the startup
+// pages.
+type StartupPhase struct {
+	Title    string
+	State    string
+	Elapsed  string
+	Progress string
+}
+
+// BootstrappingPage shows the progress of the consensus bootstrapper. Error
+// is the error that paused the bootstrapper, such as a download that kept
+// failing or a full disk. Importing is set when the consensus set is imported
+// instead of downloaded, which the user cannot pause.
+type BootstrappingPage struct {
+	Progress  string
+	Importing bool
+	Paused    bool
+	Error     string
+	Phases    []StartupPhase
+	CSRFToken string
+}
+
+// Render renders the bootstrapping page.
+func (p BootstrappingPage) Render(w io.Writer) error {
+	return execute(w, "bootstrapping.html", p)
+}
+
+// ConsensusSetBuildingPage shows the progress of the consensus builder.
+type ConsensusSetBuildingPage struct {
+	Progress string
+	Phases   []StartupPhase
+}
+
+// Render renders the consensus set building page.
+func (p ConsensusSetBuildingPage) Render(w io.Writer) error {
+	return execute(w, "consensus_set_building.html", p)
+}
+
+// InitializeConsensusSetPage asks how the consensus set should be obtained.
+// ImportPath and ImportError are the path of an archive that could not be
+// imported and why.
+type InitializeConsensusSetPage struct {
+	Message     string
+	ImportPath  string
+	ImportError string
+	CSRFToken   string
+}
+
+// Render renders the initialize consensus set page.
+func (p InitializeConsensusSetPage) Render(w io.Writer) error {
+	return execute(w, "initialize_consensus_set.html", p)
+}
+
+// ColdWalletPage shows a newly generated cold wallet.
+type ColdWalletPage struct {
+	Seed       string
+	UnlockHash string
+}
+
+// Render renders the cold wallet page.
+func (p ColdWalletPage) Render(w io.Writer) error {
+	return execute(w, "cold_wallet.html", p)
+}
+
+// StartingWalletPage is shown while the node is still starting.
+type StartingWalletPage struct {
+	Phases []StartupPhase
+}
+
+// Render renders the starting wallet page.
+func (p StartingWalletPage) Render(w io.Writer) error {
+	return execute(w, "starting_wallet.html", p)
+}
+
+// StartupFailedPage is shown when a node module failed to load. It lets the
+// user load the module again and, when the consensus set failed, reset it.
+type StartupFailedPage struct {
+	Module         string
+	Error          string
+	ResetConsensus bool
+	CSRFToken      string
+}
+
+// Render renders the startup failed page.
+func (p StartupFailedPage) Render(w io.Writer) error {
+	return execute(w, "startup_failed.html", p)
+}
+
+// InitializeWalletPage asks how a wallet should be opened.
+type InitializeWalletPage struct {
+	CSRFToken string
+}
+
+// Render renders the initialize wallet page.
+func (p InitializeWalletPage) Render(w io.Writer) error {
+	return execute(w, "initialize_wallet.html", p)
+}
+
+// InitializeSeedPage creates a new wallet.
+type InitializeSeedPage struct {
+	CSRFToken string
+}
+
+// Render renders the initialize seed page.
+func (p InitializeSeedPage) Render(w io.Writer) error {
+	return execute(w, "initialize_seed.html", p)
+}
+
+// RestoreFromSeedPage restores a wallet from a seed.
+type RestoreFromSeedPage struct {
+	CSRFToken string
+}
+
+// Render renders the restore from seed page.
+func (p RestoreFromSeedPage) Render(w io.Writer) error {
+	return execute(w, "restore_from_seed.html", p)
+}
+
+// UnlockWalletPage unlocks an existing wallet.
+type UnlockWalletPage struct {
+	CSRFToken string
+}
+
+// Render renders the unlock wallet page.
+func (p UnlockWalletPage) Render(w io.Writer) error {
+	return execute(w, "unlock_wallet.html", p)
+}
+
+// InitializeBrowserPage asks which browser the GUI should be opened in.
+type InitializeBrowserPage struct {
+	CSRFToken string
+}
+
+// Render renders the initialize browser page.
+func (p InitializeBrowserPage) Render(w io.Writer) error {
+	return execute(w, "initialize_browser.html", p)
+}
+
+// BrowserConfiguredPage is shown once the browser has been configured.
+type BrowserConfiguredPage struct{}
+
+// Render renders the browser configured page.
+func (p BrowserConfiguredPage) Render(w io.Writer) error {
+	return execute(w, "browser_configured.html", p)
+}
+
+// ChangeLockForm changes the wallet's password.
+type ChangeLockForm struct {
+	CSRFToken string
+}
+
+// Render renders the change lock form.
+func (f ChangeLockForm) Render(w io.Writer) error {
+	return execute(w, "change_lock.html", f)
+}
+
+// SendCoinsForm sends coins.
+type SendCoinsForm struct {
+	CSRFToken string
+}
+
+// Render renders the send coins form.
+func (f SendCoinsForm) Render(w io.Writer) error {
+	return execute(w, "send_coins.html", f)
+}
+
+// ReceiveCoinsForm shows the address that coins can be received at.
+type ReceiveCoinsForm struct {
+	CSRFToken string
+	Address   string
+}
+
+// Render renders the receive coins form.
+func (f ReceiveCoinsForm) Render(w io.Writer) error {
+	return execute(w, "receive_coins_form.html", f)
+}
+
+// ExplainWhaleForm explains the whale sizes.
+type ExplainWhaleForm struct {
+	CSRFToken string
+}
+
+// Render renders the explain whale form.
+func (f ExplainWhaleForm) Render(w io.Writer) error {
+	return execute(w, "explain_whale.html", f)
+}
+
+// ScanningWalletForm is shown while the wallet is being scanned.
+type ScanningWalletForm struct {
+	CSRFToken   string
+	Status      string
+	StatusColor string
+	BlockHeight string
+}
+
+// Render renders the scanning wallet form.
+func (f ScanningWalletForm) Render(w io.Writer) error {
+	return execute(w, "scanning_wallet.html", f)
+}
+
+// Privacy is the privacy policy.
+type Privacy struct {
+	CSRFToken string
+}
+
+// Render renders the privacy policy.
+func (p Privacy) Render(w io.Writer) error {
+	return execute(w, "privacy_template.html", p)
+}
+
+// TransactionHistory is a page of the wallet's transaction history.
+type TransactionHistory struct {
+	CSRFToken string
+	Lines     []TransactionHistoryLine
+	// Pages are the pages that can be selected, last page first.
+	Pages      []TransactionHistoryPage
+	PageCount  int
+	IsLastPage bool
+}
+
+// Render renders the transaction history.
+func (h TransactionHistory) Render(w io.Writer) error {
+	return execute(w, "history_template.html", h)
+}
+
+// TransactionHistoryLine is a transaction in the transaction history.
+type TransactionHistoryLine struct {
+	CSRFToken          string
+	TransactionID      string
+	ShortTransactionID string
+	Type               string
+	Amount             string
+	Time               string
+	Confirmed          string
+}
+
+// TransactionHistoryPage is a page of the transaction history that can be
+// selected.
+type TransactionHistoryPage struct {
+	Number   int
+	Selected bool
+}
+
+// TransactionInfo shows the details of a transaction.
+type TransactionInfo struct {
+	CSRFToken string
+	Type      string
+	ID        string
+	Time      string
+	Block     string
+	Inputs    []TransactionFund
+	Outputs   []TransactionFund
+}
+
+// Render renders the transaction details.
+func (t TransactionInfo) Render(w io.Writer) error {
+	return execute(w, "info_template.html", t)
+}
+
+// TransactionFund is an input or output of a transaction.
+type TransactionFund struct {
+	FundType string
+	Address  string
+	Value    string
+}
+
+// views are example view models of every template. They are rendered when
+// the package is loaded to check the templates.
+var views = []Renderer{
+	WalletPage{},
+	WalletPage{Header: Header{MenuCollapsed: true}, Portal: Privacy{}},
+	AlertPage{},
+	AlertPage{ShowStatus: true, Form: ChangeLockForm{}, Close: true},
+	ErrorPage{},
+	BootstrappingPage{Phases: []StartupPhase{{}}},
+	BootstrappingPage{Paused: true, Error: "error"},
+	BootstrappingPage{Importing: true},
+	ConsensusSetBuildingPage{Phases: []StartupPhase{{}}},
+	InitializeConsensusSetPage{},
+	InitializeConsensusSetPage{ImportError: "error"},
+	ColdWalletPage{},
+	StartingWalletPage{Phases: []StartupPhase{{}}},
+	StartupFailedPage{ResetConsensus: true},
+	InitializeWalletPage{},
+	InitializeSeedPage{},
+	RestoreFromSeedPage{},
+	UnlockWalletPage{},
+	InitializeBrowserPage{},
+	BrowserConfiguredPage{},
+	ChangeLockForm{},
+	SendCoinsForm{},
+	ReceiveCoinsForm{},
+	ExplainWhaleForm{},
+	ScanningWalletForm{},
+	Privacy{},
+	TransactionHistory{
+		Lines: []TransactionHistoryLine{{}},
+		Pages: []TransactionHistoryPage{{Selected: true}},
+	},
+	TransactionInfo{
+		Inputs:  []TransactionFund{{}},
+		Outputs: []TransactionFund{{}},
+	},
+}
@@ -0,0 +1,613 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"gitlab.com/scpcorp/ScPrime/crypto"
+	"gitlab.com/scpcorp/ScPrime/modules"
+	"gitlab.com/scpcorp/ScPrime/types"
+
+	"github.com/julienschmidt/httprouter"
+	mnemonics "gitlab.com/NebulousLabs/entropy-mnemonics"
+)
+
+// apiSessionHeader is the HTTP header that API clients use to supply the
+// session ID returned when a wallet is opened. Browsers use the session cookie
+// instead.
+const apiSessionHeader = "X-Session-ID"
+
+// apiTxHistoryPageSize is the number of transactions returned per page by the
+// transaction history API call.
+const apiTxHistoryPageSize = 20
+
+type (
+	// APIError is the error object returned by every failed API call.
+	APIError struct {
+		Message string `json:"message"`
+	}
+
+	// APIOpenWalletRequest is the request body used to unlock an existing
+	// wallet.
+	APIOpenWalletRequest struct {
+		WalletName string `json:"wallet_name"`
+		Password   string `json:"password"`
+	}
+
+	// APICreateWalletRequest is the request body used to create a new wallet.
+	APICreateWalletRequest struct {
+		WalletName string `json:"wallet_name"`
+		Password   string `json:"password"`
+	}
+
+	// APIRestoreWalletRequest is the request body used to restore a wallet
+	// from a seed.
+	APIRestoreWalletRequest struct {
+		WalletName string `json:"wallet_name"`
+		Password   string `json:"password"`
+		Seed       string `json:"seed"`
+	}
+
+	// APISessionResponse is returned when a wallet has been attached to a new
+	// session.
+	APISessionResponse struct {
+		SessionID  string `json:"session_id"`
+		WalletName string `json:"wallet_name"`
+	}
+
+	// APICreateWalletResponse is returned when a new wallet has been created.
+	APICreateWalletResponse struct {
+		APISessionResponse
+		PrimarySeed string `json:"primary_seed"`
+	}
+
+	// APIBalanceResponse contains the balances of the session's wallet. All
+	// currency values are in hastings.
+	APIBalanceResponse struct {
+		ConfirmedSCP           types.Currency `json:"confirmed_scp"`
+		ConfirmedSPF           types.Currency `json:"confirmed_spf"`
+		ClaimSCP               types.Currency `json:"claim_scp"`
+		UnconfirmedIncomingSCP types.Currency `json:"unconfirmed_incoming_scp"`
+		UnconfirmedOutgoingSCP types.Currency `json:"unconfirmed_outgoing_scp"`
+		WhaleSize              string         `json:"whale_size"`
+	}
+
+	// APIStatusResponse contains the block height and sync status of the
+	// session's wallet.
+	APIStatusResponse struct {
+		Height     types.BlockHeight `json:"height"`
+		Status     string            `json:"status"`
+		Synced     bool              `json:"synced"`
+		Rescanning bool              `json:"rescanning"`
+		// Progress is the percentage complete of the operation named by
+		// Status, when it is known.
+		Progress *int `json:"progress,omitempty"`
+	}
+
+	// APIAddressResponse contains an address that the wallet can receive
+	// coins at.
+	APIAddressResponse struct {
+		Address types.UnlockHash `json:"address"`
+	}
+
+	// APITransactionsResponse contains a page of the wallet's transaction
+	// history.
+	APITransactionsResponse struct {
+		Transactions []SummarizedTransaction `json:"transactions"`
+		Page         int                     `json:"page"`
+		Pages        int                     `json:"pages"`
+	}
+
+	// APITransactionResponse contains a single processed transaction.
+	APITransactionResponse struct {
+		Transaction modules.ProcessedTransaction `json:"transaction"`
+	}
+
+	// APISendRequest is the request body used to send coins. Amount is a
+	// decimal number of whole SCP or SPF depending on CoinType.
+	APISendRequest struct {
+		Amount      string `json:"amount"`
+		Destination string `json:"destination"`
+		CoinType    string `json:"coin_type"`
+	}
+
+	// APISendResponse contains the IDs of the transactions that were
+	// broadcast by a send.
+	APISendResponse struct {
+		TransactionIDs []types.TransactionID `json:"transaction_ids"`
+	}
+
+	// APISeedResponse contains the wallet's primary seed.
+	APISeedResponse struct {
+		PrimarySeed string `json:"primary_seed"`
+	}
+
+	// APIChangePasswordRequest is the request body used to change the
+	// wallet's password.
+	APIChangePasswordRequest struct {
+		OrigPassword string `json:"orig_password"`
+		NewPassword  string `json:"new_password"`
+	}
+)
+
+// writeJSON writes the object to the ResponseWriter as JSON with the supplied
+// status code.
+func writeJSON(w http.ResponseWriter, code int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if obj == nil {
+		return
+	}
+	// The status has already been sent, so an error here can only mean that
+	// the client has gone away.
+	_ = json.NewEncoder(w).Encode(obj)
+}
+
+// writeAPIError writes an APIError to the ResponseWriter with the supplied
+// status code.
+func writeAPIError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, APIError{Message: msg})
+}
+
+// decodeJSON decodes the request body into obj and writes a bad request error
+// when it is malformed.
+func decodeJSON(w http.ResponseWriter, req *http.Request, obj interface{}) bool {
+	err := json.NewDecoder(req.Body).Decode(obj)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("unable to parse request body: %v", err))
+		return false
+	}
+	return true
+}
+
+// apiWalletName defaults the wallet name to "wallet" when it is empty. It
+// writes a bad request error and returns false when the name is not the name
+// of a directory inside the wallets directory, so that a client cannot open or
+// create a wallet elsewhere.
+func apiWalletName(w http.ResponseWriter, name *string) bool {
+	if *name == "" {
+		*name = "wallet"
+	}
+	if *name == "." || *name == ".." || filepath.Base(*name) != *name || strings.ContainsAny(*name, `/\`) {
+		writeAPIError(w, http.StatusBadRequest, "wallet_name must be the name of a wallet")
+		return false
+	}
+	return true
+}
+
+// apiNodeReady writes a service unavailable error and returns false when the
+// node has not finished loading.
+func (s *Server) apiNodeReady(w http.ResponseWriter) bool {
+	if failure, failed := s.startupFailure(); failed {
+		writeAPIError(w, http.StatusServiceUnavailable, fmt.Sprintf("node failed to load the %s: %s", moduleTitles[failure.Name], failure.Error))
+		return false
+	}
+	if !s.nodeLoaded() {
+		writeAPIError(w, http.StatusServiceUnavailable, "node is still starting")
+		return false
+	}
+	return true
+}
+
+// apiWallet returns the wallet attached to the session supplied in the
+// request, writing an error and returning nil when there is none.
+func (s *Server) apiWallet(w http.ResponseWriter, req *http.Request) modules.Wallet {
+	if !s.apiNodeReady(w) {
+		return nil
+	}
+	sessionID := requestSessionID(req)
+	if sessionID == "" || !s.sessionIDExists(sessionID) {
+		writeAPIError(w, http.StatusUnauthorized, "session ID does not exist")
+		return nil
+	}
+	wallet, err := s.getWallet(sessionID)
+	if err != nil {
+		writeAPIError(w, http.StatusUnauthorized, err.Error())
+		return nil
+	}
+	return wallet
+}
+
+// apiUnlockedWallet is like apiWallet but also requires that the wallet is
+// unlocked.
+func (s *Server) apiUnlockedWallet(w http.ResponseWriter, req *http.Request) modules.Wallet {
+	wallet := s.apiWallet(w, req)
+	if wallet == nil {
+		return nil
+	}
+	unlocked, err := wallet.Unlocked()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return nil
+	}
+	if !unlocked {
+		writeAPIError(w, http.StatusForbidden, "wallet is locked")
+		return nil
+	}
+	return wallet
+}
+
+func apiNotReadyHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	writeAPIError(w, http.StatusServiceUnavailable, "node is still starting")
+}
+
+func (s *Server) apiOpenWalletHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	if !s.apiNodeReady(w) {
+		return
+	}
+	var body APIOpenWalletRequest
+	if !decodeJSON(w, req, &body) {
+		return
+	}
+	if !apiWalletName(w, &body.WalletName) {
+		return
+	}
+	if body.Password == "" {
+		writeAPIError(w, http.StatusBadRequest, "a password must be provided")
+		return
+	}
+	sessionID, err := s.addSessionID()
+	if err != nil {
+		writeAPIError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	wallet, err := s.existingWallet(body.WalletName, sessionID)
+	if err != nil {
+		s.logout(sessionID)
+		writeAPIError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	s.setStatus(sessionID, walletStateScanning)
+	err = unlockWallet(wallet, body.Password)
+	s.setStatus(sessionID, walletStateIdle)
+	if err != nil {
+		s.metrics.unlockFailed(unlockSourceAPI)
+		s.logout(sessionID)
+		writeAPIError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, APISessionResponse{SessionID: sessionID, WalletName: body.WalletName})
+}
+
+func (s *Server) apiCreateWalletHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	if !s.apiNodeReady(w) {
+		return
+	}
+	var body APICreateWalletRequest
+	if !decodeJSON(w, req, &body) {
+		return
+	}
+	if !apiWalletName(w, &body.WalletName) {
+		return
+	}
+	if len(body.Password) < 8 {
+		writeAPIError(w, http.StatusBadRequest, "password must be at least eight characters long")
+		return
+	}
+	sessionID, err := s.addSessionID()
+	if err != nil {
+		writeAPIError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	wallet, err := s.newWallet(body.WalletName, sessionID)
+	if err != nil {
+		s.logout(sessionID)
+		writeAPIError(w, http.StatusConflict, err.Error())
+		return
+	}
+	s.setStatus(sessionID, walletStateInitializing)
+	encryptionKey := crypto.NewWalletKey(crypto.HashObject(body.Password))
+	seed, err := wallet.Encrypt(encryptionKey)
+	if err == nil {
+		err = unlockWallet(wallet, body.Password)
+	}
+	s.setStatus(sessionID, walletStateIdle)
+	if err != nil {
+		s.logout(sessionID)
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	seedStr, err := modules.SeedToString(seed, mnemonics.English)
+	if err != nil {
+		s.logout(sessionID)
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, APICreateWalletResponse{
+		APISessionResponse: APISessionResponse{SessionID: sessionID, WalletName: body.WalletName},
+		PrimarySeed:        seedStr,
+	})
+}
+
+func (s *Server) apiRestoreWalletHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	if !s.apiNodeReady(w) {
+		return
+	}
+	var body APIRestoreWalletRequest
+	if !decodeJSON(w, req, &body) {
+		return
+	}
+	if !apiWalletName(w, &body.WalletName) {
+		return
+	}
+	if len(body.Password) < 8 {
+		writeAPIError(w, http.StatusBadRequest, "password must be at least eight characters long")
+		return
+	}
+	seed, err := modules.StringToSeed(body.Seed, mnemonics.English)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.consensusSynced() {
+		writeAPIError(w, http.StatusServiceUnavailable, "consensus set is not synced")
+		return
+	}
+	sessionID, err := s.addSessionID()
+	if err != nil {
+		writeAPIError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	wallet, err := s.newWallet(body.WalletName, sessionID)
+	if err != nil {
+		s.logout(sessionID)
+		writeAPIError(w, http.StatusConflict, err.Error())
+		return
+	}
+	s.setStatus(sessionID, walletStateRestoring)
+	encryptionKey := crypto.NewWalletKey(crypto.HashObject(body.Password))
+	err = wallet.InitFromSeed(encryptionKey, seed)
+	if err == nil {
+		err = unlockWallet(wallet, body.Password)
+	}
+	s.setStatus(sessionID, walletStateIdle)
+	if err != nil {
+		s.logout(sessionID)
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, APISessionResponse{SessionID: sessionID, WalletName: body.WalletName})
+}
+
+func (s *Server) apiLockWalletHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	wallet := s.apiWallet(w, req)
+	if wallet == nil {
+		return
+	}
+	unlocked, err := wallet.Unlocked()
+	if err == nil && unlocked {
+		err = wallet.Lock()
+	}
+	err = errors.Compose(err, s.logout(requestSessionID(req)))
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) apiLogoutHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	err := s.logout(requestSessionID(req))
+	if errors.Contains(err, errSessionNotFound) {
+		writeAPIError(w, http.StatusUnauthorized, err.Error())
+		return
+	} else if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) apiBalanceHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	wallet := s.apiUnlockedWallet(w, req)
+	if wallet == nil {
+		return
+	}
+	scpBal, spfBal, scpClaimBal, err := wallet.ConfirmedBalance()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	scpOut, scpIn, err := wallet.UnconfirmedBalance()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	_, _, _, _, fmtWhale := s.balancesHelper(requestSessionID(req))
+	writeJSON(w, http.StatusOK, APIBalanceResponse{
+		ConfirmedSCP:           scpBal,
+		ConfirmedSPF:           spfBal,
+		ClaimSCP:               scpClaimBal,
+		UnconfirmedIncomingSCP: scpIn,
+		UnconfirmedOutgoingSCP: scpOut,
+		WhaleSize:              fmtWhale,
+	})
+}
+
+func (s *Server) apiStatusHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	wallet := s.apiWallet(w, req)
+	if wallet == nil {
+		return
+	}
+	height, err := wallet.Height()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rescanning, err := wallet.Rescanning()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sessionID := requestSessionID(req)
+	_, fmtStatus, _ := s.blockHeightHelper(sessionID)
+	resp := APIStatusResponse{
+		Height:     height,
+		Status:     fmtStatus,
+		Synced:     s.consensusSynced(),
+		Rescanning: rescanning,
+	}
+	if session, err := s.getSession(sessionID); err == nil {
+		if state, progress := session.getState(); state != walletStateIdle && progress >= 0 {
+			resp.Progress = &progress
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) apiAddressHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	wallet := s.apiUnlockedWallet(w, req)
+	if wallet == nil {
+		return
+	}
+	address, err := receiveAddress(wallet)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, APIAddressResponse{Address: address})
+}
+
+func (s *Server) apiTransactionsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	wallet := s.apiUnlockedWallet(w, req)
+	if wallet == nil {
+		return
+	}
+	page := 1
+	if pageStr := req.FormValue("page"); pageStr != "" {
+		var err error
+		page, err = strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			writeAPIError(w, http.StatusBadRequest, "page must be a positive integer")
+			return
+		}
+	}
+	sts, err := s.summarizedTransactions(wallet)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	pages := (len(sts) + apiTxHistoryPageSize - 1) / apiTxHistoryPageSize
+	resp := APITransactionsResponse{Transactions: []SummarizedTransaction{}, Page: page, Pages: pages}
+	start := (page - 1) * apiTxHistoryPageSize
+	if start < len(sts) {
+		end := start + apiTxHistoryPageSize
+		if end > len(sts) {
+			end = len(sts)
+		}
+		resp.Transactions = sts[start:end]
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) apiTransactionHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	wallet := s.apiUnlockedWallet(w, req)
+	if wallet == nil {
+		return
+	}
+	var transactionID types.TransactionID
+	err := transactionID.UnmarshalJSON([]byte(`"` + ps.ByName("id") + `"`))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "unable to parse transaction ID")
+		return
+	}
+	txn, ok, err := wallet.Transaction(transactionID)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "transaction was not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, APITransactionResponse{Transaction: txn})
+}
+
+func (s *Server) apiSendHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	wallet := s.apiUnlockedWallet(w, req)
+	if wallet == nil {
+		return
+	}
+	var body APISendRequest
+	if !decodeJSON(w, req, &body) {
+		return
+	}
+	dest, err := scanAddress(body.Destination)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "destination is not valid")
+		return
+	}
+	amount, err := parseAmount(body.Amount, body.CoinType)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	txns, err := sendCoins(wallet, amount, body.CoinType, dest)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp := APISendResponse{TransactionIDs: []types.TransactionID{}}
+	for _, txn := range txns {
+		resp.TransactionIDs = append(resp.TransactionIDs, txn.ID())
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) apiSeedHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	wallet := s.apiUnlockedWallet(w, req)
+	if wallet == nil {
+		return
+	}
+	dictionary := mnemonics.DictionaryID(req.FormValue("dictionary"))
+	if dictionary == "" {
+		dictionary = mnemonics.English
+	}
+	primarySeed, _, err := wallet.PrimarySeed()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	primarySeedStr, err := modules.SeedToString(primarySeed, dictionary)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, APISeedResponse{PrimarySeed: primarySeedStr})
+}
+
+func (s *Server) apiChangePasswordHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	wallet := s.apiUnlockedWallet(w, req)
+	if wallet == nil {
+		return
+	}
+	var body APIChangePasswordRequest
+	if !decodeJSON(w, req, &body) {
+		return
+	}
+	if len(body.NewPassword) < 8 {
+		writeAPIError(w, http.StatusBadRequest, "password must be at least eight characters long")
+		return
+	}
+	validPass, err := isPasswordValid(wallet, body.OrigPassword)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	} else if !validPass {
+		writeAPIError(w, http.StatusUnauthorized, "the original password is not valid")
+		return
+	}
+	err = changeLock(wallet, body.NewPassword)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusNoContent, nil)
+}
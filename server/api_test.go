@@ -0,0 +1,198 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gitlab.com/scpcorp/ScPrime/modules"
+	"gitlab.com/scpcorp/ScPrime/modules/consensus"
+	"gitlab.com/scpcorp/ScPrime/modules/gateway"
+	"gitlab.com/scpcorp/ScPrime/modules/transactionpool"
+	"gitlab.com/scpcorp/ScPrime/node"
+)
+
+// newTestNode returns a node with the modules that wallets need, which is
+// closed when the test has finished.
+func newTestNode(t *testing.T) (*node.Node, *node.NodeParams) {
+	dir := t.TempDir()
+	g, err := gateway.New("localhost:0", false, filepath.Join(dir, modules.GatewayDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { g.Close() })
+	cs, errChan := consensus.New(g, false, filepath.Join(dir, modules.ConsensusDir))
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cs.Close() })
+	tp, err := transactionpool.New(cs, g, filepath.Join(dir, modules.TransactionPoolDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tp.Close() })
+	n := &node.Node{Dir: dir, Gateway: g, ConsensusSet: cs, TransactionPool: tp}
+	return n, &node.NodeParams{Dir: dir, CreateWallet: true}
+}
+
+// serveAPI sends an API request to the server, authenticated with the session
+// ID when it is not empty, and returns the response.
+func serveAPI(s *Server, method string, path string, sessionID string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Host = "localhost:4300"
+	req.Header.Set("Content-Type", "application/json")
+	if sessionID != "" {
+		req.Header.Set(apiSessionHeader, sessionID)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	return w
+}
+
+// TestAPINodeNotLoaded tests that the API is unavailable until the node has
+// been loaded.
+func TestAPINodeNotLoaded(t *testing.T) {
+	s := newTestServer(t)
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodPost, "/api/v1/wallet/open", `{"password":"password"}`},
+		{http.MethodPost, "/api/v1/wallet/create", `{"password":"password"}`},
+		{http.MethodGet, "/api/v1/wallet/balance", ""},
+	}
+	for _, test := range tests {
+		w := serveAPI(s, test.method, test.path, "", test.body)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%v %v: expected status %v, got %v", test.method, test.path, http.StatusServiceUnavailable, w.Code)
+		}
+	}
+}
+
+// TestAPIRequestErrors tests that requests without a session, or with a
+// malformed body, are rejected.
+func TestAPIRequestErrors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	s := newTestServer(t, WithNode(newTestNode(t)))
+	sessionID, err := s.addSessionID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		sessionID string
+		// cookie is sent as the session cookie instead of the header.
+		cookie string
+		body   string
+		want   int
+	}{
+		{name: "no session", method: http.MethodGet, path: "/api/v1/wallet/balance", want: http.StatusUnauthorized},
+		{name: "unknown session", method: http.MethodGet, path: "/api/v1/wallet/balance", sessionID: strings.Repeat("0", 32), want: http.StatusUnauthorized},
+		{name: "session cookie", method: http.MethodGet, path: "/api/v1/wallet/balance", cookie: sessionID, want: http.StatusUnauthorized},
+		{name: "session without wallet", method: http.MethodGet, path: "/api/v1/wallet/balance", sessionID: sessionID, want: http.StatusUnauthorized},
+		{name: "logout without session", method: http.MethodPost, path: "/api/v1/logout", want: http.StatusUnauthorized},
+		{name: "malformed open", method: http.MethodPost, path: "/api/v1/wallet/open", body: `{"password":`, want: http.StatusBadRequest},
+		{name: "malformed create", method: http.MethodPost, path: "/api/v1/wallet/create", body: `[]`, want: http.StatusBadRequest},
+		{name: "malformed restore", method: http.MethodPost, path: "/api/v1/wallet/restore", body: `not json`, want: http.StatusBadRequest},
+		{name: "short password", method: http.MethodPost, path: "/api/v1/wallet/create", body: `{"password":"short"}`, want: http.StatusBadRequest},
+		{name: "wallet outside wallets directory", method: http.MethodPost, path: "/api/v1/wallet/create", body: `{"wallet_name":"../../x","password":"password"}`, want: http.StatusBadRequest},
+		{name: "wallets directory", method: http.MethodPost, path: "/api/v1/wallet/open", body: `{"wallet_name":"..","password":"password"}`, want: http.StatusBadRequest},
+		{name: "missing wallet", method: http.MethodPost, path: "/api/v1/wallet/open", body: `{"wallet_name":"missing","password":"password"}`, want: http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			req.Host = "localhost:4300"
+			if test.sessionID != "" {
+				req.Header.Set(apiSessionHeader, test.sessionID)
+			}
+			if test.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: test.cookie})
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, req)
+			if w.Code != test.want {
+				t.Fatalf("expected status %v, got %v: %v", test.want, w.Code, w.Body)
+			}
+			var apiErr APIError
+			if err := json.Unmarshal(w.Body.Bytes(), &apiErr); err != nil || apiErr.Message == "" {
+				t.Fatalf("expected an API error, got %q", w.Body)
+			}
+		})
+	}
+}
+
+// TestAPIWalletLifecycle tests that a wallet can be created, logged out of,
+// opened again with its password and locked through the API.
+func TestAPIWalletLifecycle(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	s := newTestServer(t, WithNode(newTestNode(t)))
+	t.Cleanup(func() { s.CloseAllWallets() })
+
+	// Create a wallet.
+	w := serveAPI(s, http.MethodPost, "/api/v1/wallet/create", "", `{"wallet_name":"api","password":"password"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected status %v, got %v: %v", http.StatusCreated, w.Code, w.Body)
+	}
+	if cookies := w.Result().Cookies(); len(cookies) != 0 {
+		t.Fatalf("create: expected no cookies, got %v", cookies)
+	}
+	var created APICreateWalletResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.SessionID == "" || created.WalletName != "api" || created.PrimarySeed == "" {
+		t.Fatalf("create: unexpected response %+v", created)
+	}
+	if w := serveAPI(s, http.MethodGet, "/api/v1/wallet/balance", created.SessionID, ""); w.Code != http.StatusOK {
+		t.Fatalf("balance: expected status %v, got %v: %v", http.StatusOK, w.Code, w.Body)
+	}
+	if w := serveAPI(s, http.MethodPost, "/api/v1/wallet/create", "", `{"wallet_name":"api","password":"password"}`); w.Code != http.StatusConflict {
+		t.Fatalf("create again: expected status %v, got %v: %v", http.StatusConflict, w.Code, w.Body)
+	}
+
+	// Log out, which closes the wallet and ends the session.
+	if w := serveAPI(s, http.MethodPost, "/api/v1/logout", created.SessionID, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("logout: expected status %v, got %v: %v", http.StatusNoContent, w.Code, w.Body)
+	}
+	if w := serveAPI(s, http.MethodGet, "/api/v1/wallet/balance", created.SessionID, ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("balance after logout: expected status %v, got %v: %v", http.StatusUnauthorized, w.Code, w.Body)
+	}
+
+	// Open the wallet again, first with a wrong password.
+	if w := serveAPI(s, http.MethodPost, "/api/v1/wallet/open", "", `{"wallet_name":"api","password":"wrong password"}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("open with wrong password: expected status %v, got %v: %v", http.StatusUnauthorized, w.Code, w.Body)
+	}
+	w = serveAPI(s, http.MethodPost, "/api/v1/wallet/open", "", `{"wallet_name":"api","password":"password"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("open: expected status %v, got %v: %v", http.StatusOK, w.Code, w.Body)
+	}
+	var opened APISessionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &opened); err != nil {
+		t.Fatal(err)
+	}
+	if opened.SessionID == "" || opened.SessionID == created.SessionID {
+		t.Fatalf("open: expected a new session, got %q", opened.SessionID)
+	}
+	if w := serveAPI(s, http.MethodGet, "/api/v1/wallet/status", opened.SessionID, ""); w.Code != http.StatusOK {
+		t.Fatalf("status: expected status %v, got %v: %v", http.StatusOK, w.Code, w.Body)
+	}
+
+	// Lock the wallet, which also ends the session.
+	if w := serveAPI(s, http.MethodPost, "/api/v1/wallet/lock", opened.SessionID, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("lock: expected status %v, got %v: %v", http.StatusNoContent, w.Code, w.Body)
+	}
+	if w := serveAPI(s, http.MethodPost, "/api/v1/logout", opened.SessionID, ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("logout after lock: expected status %v, got %v: %v", http.StatusUnauthorized, w.Code, w.Body)
+	}
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"gitlab.com/scpcorp/ScPrime/modules"
+	"gitlab.com/scpcorp/ScPrime/node"
+
+	"gitlab.com/scpcorp/webwallet/logger"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// eventIdleInterval is how often an event stream checks the wallet when
+	// no consensus or transaction pool update has arrived.
+	eventIdleInterval = 10 * time.Second
+
+	// eventBusyInterval is how often an event stream checks the wallet while
+	// a long running operation is performed on it, since scanning progress is
+	// not announced by the consensus set.
+	eventBusyInterval = time.Second
+)
+
+// walletEvent is the wallet state pushed to the GUI by the event stream.
+type walletEvent struct {
+	Height           string `json:"height"`
+	Status           string `json:"status"`
+	StatusColor      string `json:"status_color"`
+	SCPBalance       string `json:"scp_balance"`
+	UnconfirmedDelta string `json:"unconfirmed_delta"`
+	SPFBalance       string `json:"spf_balance"`
+	SCPClaimBalance  string `json:"scp_claim_balance"`
+	WhaleSize        string `json:"whale_size"`
+}
+
+// updateNotifier subscribes to the consensus set and the transaction pool and
+// wakes up the event streams whenever either of them changes.
+type updateNotifier struct {
+	mu        sync.Mutex
+	listeners map[chan struct{}]struct{}
+
+	// subscribeMu guards subscribed. It is separate from mu because the
+	// consensus set calls the notifier while it is being subscribed.
+	subscribeMu sync.Mutex
+	subscribed  bool
+}
+
+// newUpdateNotifier returns an update notifier without listeners.
+func newUpdateNotifier() *updateNotifier {
+	return &updateNotifier{
+		listeners: make(map[chan struct{}]struct{}),
+	}
+}
+
+// ProcessConsensusChange implements modules.ConsensusSetSubscriber.
+func (n *updateNotifier) ProcessConsensusChange(modules.ConsensusChange) {
+	n.notify()
+}
+
+// ReceiveUpdatedUnconfirmedTransactions implements
+// modules.TransactionPoolSubscriber.
+func (n *updateNotifier) ReceiveUpdatedUnconfirmedTransactions(*modules.TransactionPoolDiff) {
+	n.notify()
+}
+
+// notify wakes up every listener without blocking the caller, which holds the
+// consensus set or transaction pool lock.
+func (n *updateNotifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for ch := range n.listeners {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// listen returns a channel that receives a value after every update.
+func (n *updateNotifier) listen() chan struct{} {
+	ch := make(chan struct{}, 1)
+	n.mu.Lock()
+	n.listeners[ch] = struct{}{}
+	n.mu.Unlock()
+	return ch
+}
+
+// stopListening removes the listener.
+func (n *updateNotifier) stopListening(ch chan struct{}) {
+	n.mu.Lock()
+	delete(n.listeners, ch)
+	n.mu.Unlock()
+}
+
+// subscribeNode subscribes the update notifier to the node's consensus set and
+// transaction pool. The node is attached before its modules are loaded, so
+// this does nothing until both modules exist and is safe to call repeatedly.
+func (s *Server) subscribeNode() {
+	s.updates.subscribeMu.Lock()
+	defer s.updates.subscribeMu.Unlock()
+	if s.updates.subscribed {
+		return
+	}
+	s.withNode(func(node *node.Node) {
+		if node.ConsensusSet == nil || node.TransactionPool == nil {
+			return
+		}
+		err := node.ConsensusSet.ConsensusSetSubscribe(s.updates, modules.ConsensusChangeRecent, s.stopCh)
+		if err != nil {
+			s.log.Error("Unable to subscribe to the consensus set", logger.Err(err))
+			return
+		}
+		node.TransactionPool.TransactionPoolSubscribe(s.updates)
+		s.updates.subscribed = true
+	})
+}
+
+// unsubscribeNode removes the update notifier from the node's consensus set
+// and transaction pool.
+func (s *Server) unsubscribeNode() {
+	s.updates.subscribeMu.Lock()
+	defer s.updates.subscribeMu.Unlock()
+	s.withNode(s.removeSubscriptions)
+}
+
+// removeSubscriptions removes the update notifier from the node's consensus
+// set and transaction pool. The update notifier's subscribeMu must be held.
+func (s *Server) removeSubscriptions(node *node.Node) {
+	if !s.updates.subscribed {
+		return
+	}
+	node.ConsensusSet.Unsubscribe(s.updates)
+	node.TransactionPool.Unsubscribe(s.updates)
+	s.updates.subscribed = false
+}
+
+// walletEvent returns the session's current wallet state.
+func (s *Server) walletEvent(sessionID string) walletEvent {
+	var event walletEvent
+	event.Height, event.Status, event.StatusColor = s.blockHeightHelper(sessionID)
+	event.SCPBalance, event.UnconfirmedDelta, event.SPFBalance, event.SCPClaimBalance, event.WhaleSize = s.balancesHelper(sessionID)
+	return event
+}
+
+// eventsHandler streams the session's balances, block height and status as
+// server-sent events. A new event is only sent when the state has changed.
+func (s *Server) eventsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	s.subscribeNode()
+	sessionID := requestSessionID(req)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	updates := s.updates.listen()
+	defer s.updates.stopListening(updates)
+	var last walletEvent
+	for first := true; ; first = false {
+		event := s.walletEvent(sessionID)
+		if first || event != last {
+			data, err := json.Marshal(event)
+			if err != nil {
+				s.sessionLog(sessionID).Error("Unable to encode wallet event", logger.Err(err))
+				return
+			}
+			fmt.Fprintf(w, "event: wallet\ndata: %s\n\n", data)
+			flusher.Flush()
+			last = event
+		}
+		interval := eventIdleInterval
+		if s.getStatus(sessionID) != walletStateIdle {
+			interval = eventBusyInterval
+		}
+		select {
+		case <-req.Context().Done():
+			return
+		case <-s.stopCh:
+			return
+		case <-updates:
+		case <-time.After(interval):
+		}
+	}
+}
@@ -1,9 +1,7 @@
 package server
 
 import (
-	"context"
-	"crypto/rand"
-	"encoding/hex"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"math/big"
@@ -13,6 +11,7 @@ import (
 	"time"
 
 	"gitlab.com/scpcorp/webwallet/build"
+	"gitlab.com/scpcorp/webwallet/logger"
 	"gitlab.com/scpcorp/webwallet/modules/bootstrapper"
 	"gitlab.com/scpcorp/webwallet/modules/browserconfig"
 	consensusbuilder "gitlab.com/scpcorp/webwallet/modules/consensesbuilder"
@@ -45,30 +44,35 @@ func faviconHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Param
 	w.Write(favicon)
 }
 
-func balanceHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	sessionID := req.FormValue("session_id")
-	fmtScpBal, fmtUncBal, fmtSpfBal, fmtClmBal, fmtWhale := balancesHelper(sessionID)
+func (s *Server) balanceHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sessionID := requestSessionID(req)
+	fmtScpBal, fmtUncBal, fmtSpfBal, fmtClmBal, fmtWhale := s.balancesHelper(sessionID)
 	writeArray(w, []string{fmtScpBal, fmtUncBal, fmtSpfBal, fmtClmBal, fmtWhale})
 }
 
-func blockHeightHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	sessionID := req.FormValue("session_id")
-	fmtHeight, fmtStatus, fmtStatCo := blockHeightHelper(sessionID)
+func (s *Server) blockHeightHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sessionID := requestSessionID(req)
+	fmtHeight, fmtStatus, fmtStatCo := s.blockHeightHelper(sessionID)
 	writeArray(w, []string{fmtHeight, fmtStatus, fmtStatCo})
 }
 
+// bootstrapperProgressHandler returns the progress of the bootstrapper's
+// current stage, including the bytes done, the throughput and the estimated
+// time remaining.
 func bootstrapperProgressHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	writeArray(w, []string{bootstrapper.Progress()})
+	writeJSON(w, http.StatusOK, bootstrapper.GetTelemetry())
 }
 
 func consensusBuilderProgressHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	writeArray(w, []string{consensusbuilder.Progress()})
 }
 
-func heartbeatHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	sessionID := req.FormValue("session_id")
-	updateHeartbeat(sessionID)
-	go shutdownHelper(sessionID)
+func (s *Server) heartbeatHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sessionID := requestSessionID(req)
+	s.updateHeartbeat(sessionID)
+	if !s.headless {
+		go s.shutdownHelper(sessionID)
+	}
 	writeArray(w, []string{"true"})
 }
 
@@ -107,8 +111,12 @@ func openSansLatin700Woff2Handler(w http.ResponseWriter, req *http.Request, _ ht
 	w.Write(font)
 }
 
-func transactionHistoryCsvExport(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	history, err := transctionHistoryCsvExportHelper()
+func (s *Server) transactionHistoryCsvExport(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	history := "failed"
+	wallet, err := s.getWallet(requestSessionID(req))
+	if err == nil {
+		history, err = s.transctionHistoryCsvExportHelper(wallet)
+	}
 	if err != nil {
 		history = "failed"
 	}
@@ -118,18 +126,19 @@ func transactionHistoryCsvExport(w http.ResponseWriter, req *http.Request, _ htt
 	w.Write([]byte(history))
 }
 
-func transctionHistoryCsvExportHelper() (string, error) {
+func (s *Server) transctionHistoryCsvExportHelper(wallet modules.Wallet) (string, error) {
 	csv := `"Transaction ID","Type","Amount SCP","Amount SPF","Confirmed","DateTime"` + "\n"
 	heightMin := 0
-	confirmedTxns, err := n.Wallet.Transactions(types.BlockHeight(heightMin), n.ConsensusSet.Height())
+	height := s.consensusHeight()
+	confirmedTxns, err := wallet.Transactions(types.BlockHeight(heightMin), height)
 	if err != nil {
 		return "", err
 	}
-	unconfirmedTxns, err := n.Wallet.UnconfirmedTransactions()
+	unconfirmedTxns, err := wallet.UnconfirmedTransactions()
 	if err != nil {
 		return "", err
 	}
-	sts, err := ComputeSummarizedTransactions(append(confirmedTxns, unconfirmedTxns...), n.ConsensusSet.Height())
+	sts, err := ComputeSummarizedTransactions(append(confirmedTxns, unconfirmedTxns...), height)
 	if err != nil {
 		return "", err
 	}
@@ -146,107 +155,91 @@ func transctionHistoryCsvExportHelper() (string, error) {
 	return csv, nil
 }
 
-func privacyHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	sessionID := req.FormValue("session_id")
-	html := resources.WalletHTMLTemplate()
-	html = strings.Replace(html, "&TRANSACTION_PORTAL;", resources.PrivacyHTMLTemplate(), -1)
-	writeHTML(w, html, sessionID)
+func (s *Server) privacyHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sessionID := requestSessionID(req)
+	page := resources.WalletPage{Portal: resources.Privacy{CSRFToken: s.csrfToken(sessionID)}}
+	s.writeHTML(w, page, sessionID)
 }
 
-func alertChangeLockHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	sessionID := req.FormValue("session_id")
-	if sessionID == "" || !sessionIDExists(sessionID) {
+func (s *Server) alertChangeLockHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sessionID := requestSessionID(req)
+	if sessionID == "" || !s.sessionIDExists(sessionID) {
 		msg := "Session ID does not exist."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 	}
 	title := "CHANGE LOCK"
-	form := resources.ChangeLockForm()
-	writeForm(w, title, form, sessionID)
+	form := resources.ChangeLockForm{CSRFToken: s.csrfToken(sessionID)}
+	s.writeForm(w, title, form, sessionID)
 }
 
-func alertInitializeSeedHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	writeStaticHTML(w, resources.InitializeSeedForm(), "")
+func (s *Server) alertInitializeSeedHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	s.writePage(w, resources.InitializeSeedPage{CSRFToken: s.formCSRFToken(req)})
 }
 
-func alertSendCoinsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	sessionID := req.FormValue("session_id")
-	if sessionID == "" || !sessionIDExists(sessionID) {
+func (s *Server) alertSendCoinsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sessionID := requestSessionID(req)
+	if sessionID == "" || !s.sessionIDExists(sessionID) {
 		msg := "Session ID does not exist."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 	}
 	title := "SEND"
-	form := resources.SendCoinsForm()
-	writeForm(w, title, form, sessionID)
+	form := resources.SendCoinsForm{CSRFToken: s.csrfToken(sessionID)}
+	s.writeForm(w, title, form, sessionID)
 }
 
-func alertReceiveCoinsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	sessionID := req.FormValue("session_id")
-	if sessionID == "" || !sessionIDExists(sessionID) {
+func (s *Server) alertReceiveCoinsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sessionID := requestSessionID(req)
+	if sessionID == "" || !s.sessionIDExists(sessionID) {
 		msg := "Session ID does not exist."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 	}
 	var msgPrefix = "Unable to retrieve address: "
-	wallet, err := getWallet(sessionID)
+	wallet, err := s.getWallet(sessionID)
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
-	addresses, err := wallet.LastAddresses(1)
-	if err != nil {
-		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, sessionID)
-		return
-	}
-	if len(addresses) == 0 {
-		_, err := wallet.NextAddress()
-		if err != nil {
-			msg := fmt.Sprintf("%s%v", msgPrefix, err)
-			writeError(w, msg, sessionID)
-			return
-		}
-	}
-	addresses, err = wallet.LastAddresses(1)
+	unlockHash, err := receiveAddress(wallet)
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
-	address := strings.ToUpper(fmt.Sprintf("%s", addresses[0]))
+	address := strings.ToUpper(fmt.Sprintf("%s", unlockHash))
 	title := "RECEIVE"
-	formHTML := resources.ReceiveCoinsForm()
-	formHTML = strings.Replace(formHTML, "&ADDRESS;", address, -1)
-	writeForm(w, title, formHTML, sessionID)
+	form := resources.ReceiveCoinsForm{CSRFToken: s.csrfToken(sessionID), Address: address}
+	s.writeForm(w, title, form, sessionID)
 }
 
-func alertRecoverSeedHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	sessionID := req.FormValue("session_id")
-	if sessionID == "" || !sessionIDExists(sessionID) {
+func (s *Server) alertRecoverSeedHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sessionID := requestSessionID(req)
+	if sessionID == "" || !s.sessionIDExists(sessionID) {
 		msg := "Session ID does not exist."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
 	cancel := req.FormValue("cancel")
 	var msgPrefix = "Unable to recover seed: "
 	if cancel == "true" {
-		guiHandler(w, req, nil)
+		s.guiHandler(w, req, nil)
 		return
 	}
-	wallet, err := getWallet(sessionID)
+	wallet, err := s.getWallet(sessionID)
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
 	unlocked, err := wallet.Unlocked()
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
 	if !unlocked {
 		msg := msgPrefix + "Wallet is locked."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
 	// Get the primary seed information.
@@ -257,33 +250,33 @@ func alertRecoverSeedHandler(w http.ResponseWriter, req *http.Request, _ httprou
 	primarySeed, _, err := wallet.PrimarySeed()
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
 	primarySeedStr, err := modules.SeedToString(primarySeed, dictionary)
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
 	title := "RECOVER SEED"
 	msg := fmt.Sprintf("%s", primarySeedStr)
-	writeMsg(w, title, msg, sessionID)
+	s.writeMsg(w, title, msg, sessionID)
 }
 
-func alertRestoreFromSeedHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	writeStaticHTML(w, resources.RestoreFromSeedForm(), "")
+func (s *Server) alertRestoreFromSeedHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	s.writePage(w, resources.RestoreFromSeedPage{CSRFToken: s.formCSRFToken(req)})
 }
 
-func unlockWalletFormHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	writeStaticHTML(w, resources.UnlockWalletForm(), "")
+func (s *Server) unlockWalletFormHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	s.writePage(w, resources.UnlockWalletPage{CSRFToken: s.formCSRFToken(req)})
 }
 
-func changeLockHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	sessionID := req.FormValue("session_id")
-	if sessionID == "" || !sessionIDExists(sessionID) {
+func (s *Server) changeLockHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sessionID := requestSessionID(req)
+	if sessionID == "" || !s.sessionIDExists(sessionID) {
 		msg := "Session ID does not exist."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 	}
 	cancel := req.FormValue("cancel")
 	origPassword := req.FormValue("orig_password")
@@ -291,63 +284,60 @@ func changeLockHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Pa
 	confirmPassword := req.FormValue("confirm_password")
 	var msgPrefix = "Unable to change lock: "
 	if cancel == "true" {
-		guiHandler(w, req, nil)
+		s.guiHandler(w, req, nil)
 		return
 	}
 	if origPassword == "" {
 		msg := msgPrefix + "The original password must be provided."
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
 	if newPassword == "" {
 		msg := msgPrefix + "A new password must be provided."
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
 	if len(newPassword) < 8 {
 		msg := msgPrefix + "Password must be at least eight characters long."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
 	if confirmPassword == "" {
 		msg := msgPrefix + "A confirmation password must be provided."
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
 	if newPassword != confirmPassword {
 		msg := msgPrefix + "New password does not match confirmation password."
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
-	wallet, err := getWallet(sessionID)
+	wallet, err := s.getWallet(sessionID)
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
 	validPass, err := isPasswordValid(wallet, origPassword)
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	} else if !validPass {
 		msg := msgPrefix + "The original password is not valid."
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
-	var newKey crypto.CipherKey
-	newKey = crypto.NewWalletKey(crypto.HashObject(newPassword))
-	primarySeed, _, _ := wallet.PrimarySeed()
-	err = wallet.ChangeKeyWithSeed(primarySeed, newKey)
+	err = changeLock(wallet, newPassword)
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
-	guiHandler(w, req, nil)
+	s.guiHandler(w, req, nil)
 }
 
-func initializeSeedHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+func (s *Server) initializeSeedHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	cancel := req.FormValue("cancel")
 	walletDirName := req.FormValue("wallet_dir_name")
 	if walletDirName == "" {
@@ -357,88 +347,95 @@ func initializeSeedHandler(w http.ResponseWriter, req *http.Request, _ httproute
 	confirmPassword := req.FormValue("confirm_password")
 	var msgPrefix = "Unable to initialize new wallet seed: "
 	if cancel == "true" {
-		guiHandler(w, req, nil)
+		s.guiHandler(w, req, nil)
 		return
 	}
 	if newPassword == "" {
 		msg := msgPrefix + "A new password must be provided."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
 	if len(newPassword) < 8 {
 		msg := msgPrefix + "Password must be at least eight characters long."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
 	if confirmPassword == "" {
 		msg := msgPrefix + "A confirmation password must be provided."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
 	if newPassword != confirmPassword {
 		msg := msgPrefix + "New password does not match confirmation password."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
+		return
+	}
+	sessionID, err := s.addSessionID()
+	if err != nil {
+		msg := fmt.Sprintf("%s%v", msgPrefix, err)
+		s.writeError(w, msg)
 		return
 	}
-	sessionID := addSessionID()
-	wallet, err := newWallet(walletDirName, sessionID)
+	setSessionCookie(w, sessionID)
+	wallet, err := s.newWallet(walletDirName, sessionID)
 	if err != nil {
+		s.logout(sessionID)
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
 	encrypted, err := wallet.Encrypted()
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
 	if encrypted {
 		msg := msgPrefix + "Seed was already initialized."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
-	go initializeSeedHelper(newPassword, sessionID)
-	title := "<font class='status &STATUS_COLOR;'>&STATUS;</font> WALLET"
-	form := resources.ScanningWalletForm()
-	writeForm(w, title, form, sessionID)
+	s.setStatus(sessionID, walletStateInitializing)
+	s.goBackground(func() { s.initializeSeedHelper(newPassword, sessionID) })
+	s.writeScanning(w, sessionID)
 }
 
-func lockWalletHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	sessionID := req.FormValue("session_id")
-	if sessionID == "" || !sessionIDExists(sessionID) {
+func (s *Server) lockWalletHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sessionID := requestSessionID(req)
+	if sessionID == "" || !s.sessionIDExists(sessionID) {
 		msg := "Session ID does not exist."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 	}
 	cancel := req.FormValue("cancel")
 	var msgPrefix = "Unable to lock wallet: "
 	if cancel == "true" {
-		guiHandler(w, req, nil)
+		s.guiHandler(w, req, nil)
 		return
 	}
-	wallet, err := getWallet(sessionID)
+	wallet, err := s.getWallet(sessionID)
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
 	unlocked, err := wallet.Unlocked()
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
 	if !unlocked {
 		msg := msgPrefix + "Wallet was already locked."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
 	wallet.Lock()
-	closeWallet(sessionID)
+	s.logout(sessionID)
+	clearSessionCookie(w)
 	redirect(w, req, nil)
 }
 
-func restoreSeedHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+func (s *Server) restoreSeedHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	cancel := req.FormValue("cancel")
 	walletDirName := req.FormValue("wallet_dir_name")
 	if walletDirName == "" {
@@ -449,150 +446,141 @@ func restoreSeedHandler(w http.ResponseWriter, req *http.Request, _ httprouter.P
 	seedStr := req.FormValue("seed_str")
 	var msgPrefix = "Unable to restore wallet from seed: "
 	if cancel == "true" {
-		guiHandler(w, req, nil)
+		s.guiHandler(w, req, nil)
 		return
 	}
 	if newPassword == "" {
 		msg := msgPrefix + "A new password must be provided."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
 	if confirmPassword == "" {
 		msg := msgPrefix + "A confirmation password must be provided."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
 	if newPassword != confirmPassword {
 		msg := msgPrefix + "New password does not match confirmation password."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
 	if seedStr == "" {
 		msg := msgPrefix + "A seed must be provided."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
-	sessionID := addSessionID()
-	wallet, err := newWallet(walletDirName, sessionID)
+	sessionID, err := s.addSessionID()
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, "")
+		s.writeError(w, msg)
+		return
+	}
+	setSessionCookie(w, sessionID)
+	wallet, err := s.newWallet(walletDirName, sessionID)
+	if err != nil {
+		s.logout(sessionID)
+		msg := fmt.Sprintf("%s%v", msgPrefix, err)
+		s.writeError(w, msg)
 		return
 	}
 	encrypted, err := wallet.Encrypted()
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
 	if encrypted {
 		msg := msgPrefix + "Seed is already initialized."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
 	seed, err := modules.StringToSeed(seedStr, "english")
 	if err != nil {
-		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, "")
+		// The error may quote a word of the seed, so it is not shown or
+		// logged.
+		msg := msgPrefix + "The seed is not valid."
+		s.writeError(w, msg)
 		return
 	}
-	go restoreSeedHelper(newPassword, seed, sessionID)
-	title := "<font class='status &STATUS_COLOR;'>&STATUS;</font> WALLET"
-	form := resources.ScanningWalletForm()
-	writeForm(w, title, form, sessionID)
+	s.setStatus(sessionID, walletStateRestoring)
+	s.goBackground(func() { s.restoreSeedHelper(newPassword, seed, sessionID) })
+	s.writeScanning(w, sessionID)
 }
 
-func sendCoinsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	sessionID := req.FormValue("session_id")
-	if sessionID == "" || !sessionIDExists(sessionID) {
+func (s *Server) sendCoinsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sessionID := requestSessionID(req)
+	if sessionID == "" || !s.sessionIDExists(sessionID) {
 		msg := "Session ID does not exist."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 	}
 	cancel := req.FormValue("cancel")
 	var msgPrefix = "Unable to send coins: "
 	if cancel == "true" {
-		guiHandler(w, req, nil)
+		s.guiHandler(w, req, nil)
 		return
 	}
-	wallet, err := getWallet(sessionID)
+	wallet, err := s.getWallet(sessionID)
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
 	unlocked, err := wallet.Unlocked()
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
 	if !unlocked {
 		msg := msgPrefix + "Wallet is locked."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 		return
 	}
 	// Verify destination address was supplied.
 	dest, err := scanAddress(req.FormValue("destination"))
 	if err != nil {
 		msg := msgPrefix + "Destination is not valid."
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
 	coinType := req.FormValue("coin_type")
-	if coinType == "SCP" {
-		amount, err := NewCurrencyStr(req.FormValue("amount") + "SCP")
-		if err != nil {
-			msg := fmt.Sprintf("%s%v", msgPrefix, err)
-			writeError(w, msg, sessionID)
-			return
-		}
-		_, err = wallet.SendSiacoins(amount, dest)
-		if err != nil {
-			msg := fmt.Sprintf("%s%v", msgPrefix, err)
-			writeError(w, msg, sessionID)
-			return
-		}
-	} else if coinType == "SPF" {
-		amount, err := NewCurrencyStr(req.FormValue("amount") + "SPF")
-		if err != nil {
-			msg := fmt.Sprintf("%s%v", msgPrefix, err)
-			writeError(w, msg, sessionID)
-			return
-		}
-		_, err = wallet.SendSiafunds(amount, dest)
-		if err != nil {
-			msg := fmt.Sprintf("%s%v", msgPrefix, err)
-			writeError(w, msg, sessionID)
-			return
-		}
-	} else {
-		msg := msgPrefix + "Coin type was not supplied."
-		writeError(w, msg, sessionID)
+	amount, err := parseAmount(req.FormValue("amount"), coinType)
+	if err != nil {
+		msg := fmt.Sprintf("%s%v", msgPrefix, err)
+		s.writeError(w, msg)
+		return
+	}
+	_, err = sendCoins(wallet, amount, coinType, dest)
+	if err != nil {
+		msg := fmt.Sprintf("%s%v", msgPrefix, err)
+		s.writeError(w, msg)
 		return
 	}
-	guiHandler(w, req, nil)
+	s.guiHandler(w, req, nil)
 }
 
-func unlockWalletHelper(wallet modules.Wallet, password string, sessionID string) {
+func (s *Server) unlockWalletHelper(wallet modules.Wallet, password string, sessionID string) {
 	var msgPrefix = "Unable to unlock wallet: "
+	err := unlockWallet(wallet, password)
+	if err != nil {
+		s.metrics.unlockFailed(unlockSourceGUI)
+		msg := fmt.Sprintf("%s%v", msgPrefix, err)
+		s.setAlert(msg, sessionID)
+	}
+	s.setStatus(sessionID, walletStateIdle)
+}
+
+// unlockWallet tries each of the encryption keys that can be derived from the
+// password until the wallet is unlocked.
+func unlockWallet(wallet modules.Wallet, password string) error {
 	if password == "" {
-		msg := "A password must be provided."
-		setAlert(msgPrefix+msg, sessionID)
-		if status == "Scanning" {
-			status = ""
-		}
-		return
+		return errors.New("a password must be provided")
 	}
 	potentialKeys, _ := encryptionKeys(password)
 	for _, key := range potentialKeys {
 		unlocked, err := wallet.Unlocked()
 		if err != nil {
-			msg := fmt.Sprintf("%s%v", msgPrefix, err)
-			setAlert(msg, sessionID)
-			if status == "Scanning" {
-				status = ""
-			}
-			return
+			return err
 		}
 		if !unlocked {
 			wallet.Unlock(key)
@@ -600,24 +588,64 @@ func unlockWalletHelper(wallet modules.Wallet, password string, sessionID string
 	}
 	unlocked, err := wallet.Unlocked()
 	if err != nil {
-		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		setAlert(msg, sessionID)
-		if status == "Scanning" {
-			status = ""
-		}
-		return
+		return err
 	}
 	if !unlocked {
-		msg := msgPrefix + "Password is not valid."
-		setAlert(msg, sessionID)
+		return errors.New("password is not valid")
+	}
+	return nil
+}
+
+// changeLock re-encrypts the wallet with a key derived from the new password.
+func changeLock(wallet modules.Wallet, newPassword string) error {
+	newKey := crypto.NewWalletKey(crypto.HashObject(newPassword))
+	primarySeed, _, err := wallet.PrimarySeed()
+	if err != nil {
+		return err
 	}
-	status = ""
+	return wallet.ChangeKeyWithSeed(primarySeed, newKey)
 }
 
-func unlockWalletHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+// receiveAddress returns the last address generated by the wallet, generating
+// one first when the wallet has none.
+func receiveAddress(wallet modules.Wallet) (types.UnlockHash, error) {
+	addresses, err := wallet.LastAddresses(1)
+	if err != nil {
+		return types.UnlockHash{}, err
+	}
+	if len(addresses) == 0 {
+		uc, err := wallet.NextAddress()
+		if err != nil {
+			return types.UnlockHash{}, err
+		}
+		return uc.UnlockHash(), nil
+	}
+	return addresses[0], nil
+}
+
+// parseAmount parses a decimal amount of whole coins of the supplied coin type.
+func parseAmount(amount string, coinType string) (types.Currency, error) {
+	if coinType != "SCP" && coinType != "SPF" {
+		return types.Currency{}, errInvalidCoinType
+	}
+	return NewCurrencyStr(amount + coinType)
+}
+
+// sendCoins sends the amount of the supplied coin type to the destination.
+func sendCoins(wallet modules.Wallet, amount types.Currency, coinType string, dest types.UnlockHash) ([]types.Transaction, error) {
+	switch coinType {
+	case "SCP":
+		return wallet.SendSiacoins(amount, dest)
+	case "SPF":
+		return wallet.SendSiafunds(amount, dest)
+	}
+	return nil, errInvalidCoinType
+}
+
+func (s *Server) unlockWalletHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	cancel := req.FormValue("cancel")
 	if cancel == "true" {
-		guiHandler(w, req, nil)
+		s.guiHandler(w, req, nil)
 		return
 	}
 	password := req.FormValue("password")
@@ -625,46 +653,51 @@ func unlockWalletHandler(w http.ResponseWriter, req *http.Request, _ httprouter.
 	if walletDirName == "" {
 		walletDirName = "wallet"
 	}
-	sessionID := addSessionID()
-	wallet, err := existingWallet(walletDirName, sessionID)
+	sessionID, err := s.addSessionID()
+	if err != nil {
+		msg := fmt.Sprintf("Unable to unlock wallet: %v", err)
+		s.writeError(w, msg)
+		return
+	}
+	setSessionCookie(w, sessionID)
+	wallet, err := s.existingWallet(walletDirName, sessionID)
 	if err != nil {
+		s.logout(sessionID)
 		msg := fmt.Sprintf("Unable to unlock wallet: %v", err)
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
-	status = "Scanning"
-	go unlockWalletHelper(wallet, password, sessionID)
+	s.setStatus(sessionID, walletStateScanning)
+	s.goBackground(func() { s.unlockWalletHelper(wallet, password, sessionID) })
 	time.Sleep(300 * time.Millisecond)
-	if status != "" {
-		title := "<font class='status &STATUS_COLOR;'>&STATUS;</font> WALLET"
-		form := resources.ScanningWalletForm()
-		writeForm(w, title, form, sessionID)
+	if s.getStatus(sessionID) != walletStateIdle {
+		s.writeScanning(w, sessionID)
 		return
 	}
-	writeWallet(w, wallet, sessionID)
+	s.writeWallet(w, wallet, sessionID)
 }
 
-func explainWhaleHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	sessionID := req.FormValue("session_id")
-	if sessionID == "" || !sessionIDExists(sessionID) {
+func (s *Server) explainWhaleHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sessionID := requestSessionID(req)
+	if sessionID == "" || !s.sessionIDExists(sessionID) {
 		msg := "Session ID does not exist."
-		writeError(w, msg, "")
+		s.writeError(w, msg)
 	}
 	title := "WHAT WHALE ARE YOU?"
-	form := resources.ExplainWhaleForm()
-	writeForm(w, title, form, sessionID)
+	form := resources.ExplainWhaleForm{CSRFToken: s.csrfToken(sessionID)}
+	s.writeForm(w, title, form, sessionID)
 }
 
-func explorerHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	sessionID := req.FormValue("session_id")
-	if sessionID == "" || !sessionIDExists(sessionID) {
+func (s *Server) explorerHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sessionID := requestSessionID(req)
+	if sessionID == "" || !s.sessionIDExists(sessionID) {
 		redirect(w, req, nil)
 		return
 	}
 	var msgPrefix = "Unable to retrieve the transaction: "
 	if req.FormValue("transaction_id") == "" {
 		msg := msgPrefix + "No transaction ID was provided."
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
 	var transactionID types.TransactionID
@@ -672,42 +705,43 @@ func explorerHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Para
 	err := transactionID.UnmarshalJSON([]byte(jsonID))
 	if err != nil {
 		msg := msgPrefix + "Unable to parse transaction ID."
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
-	wallet, err := getWallet(sessionID)
+	wallet, err := s.getWallet(sessionID)
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
 	txn, ok, err := wallet.Transaction(transactionID)
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
 	if !ok {
 		msg := msgPrefix + "Transaction was not found."
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
-	transactionDetails, _ := transactionExplorerHelper(txn)
-	html := resources.WalletHTMLTemplate()
-	html = strings.Replace(html, "&TRANSACTION_PORTAL;", transactionDetails, -1)
-	writeHTML(w, html, sessionID)
+	transactionDetails := transactionExplorerHelper(txn)
+	transactionDetails.CSRFToken = s.csrfToken(sessionID)
+	s.writeHTML(w, resources.WalletPage{Portal: transactionDetails}, sessionID)
 }
 
-func configureBrowser(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+func (s *Server) configureBrowser(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	browser := req.FormValue("browser")
-	browserconfig.Configure(build.ScPrimeWebWalletDir(), browser)
+	err := browserconfig.Configure(s.dataDir, browser)
+	if err != nil {
+		s.log.Error("Unable to save the browser config", logger.Err(err))
+	}
 	if browser != "default" {
-		html := resources.BrowserConfigured()
-		writeStaticHTML(w, html, "")
+		s.writePage(w, resources.BrowserConfiguredPage{})
 		return
 	}
 	for i := 0; i < 10; i++ {
-		if n != nil {
+		if s.nodeAttached() {
 			break
 		}
 		time.Sleep(25 * time.Millisecond)
@@ -715,60 +749,103 @@ func configureBrowser(w http.ResponseWriter, req *http.Request, _ httprouter.Par
 	redirect(w, req, nil)
 }
 
-func initializingNodeHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+func (s *Server) initializingNodeHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	if s.nodeReleased() {
+		// The node's modules are being loaded again after a failure.
+		s.writePage(w, resources.StartingWalletPage{Phases: s.startupPhaseViews()})
+		return
+	}
 	browserconfig.Initialize()
 	if browserconfig.Status() == browserconfig.Waiting {
-		writeStaticHTML(w, resources.InitializeBrowserForm(), "")
+		s.writePage(w, resources.InitializeBrowserPage{CSRFToken: s.formCSRFToken(req)})
 	} else if consensusbuilder.Progress() != "" {
-		buildingConsensusSetHandler(w, req, nil)
+		s.buildingConsensusSetHandler(w, req, nil)
 	} else if bootstrapper.Progress() != "" {
-		bootstrappingHandler(w, req, nil)
+		s.bootstrappingHandler(w, req, nil)
 	} else {
-		initializeConsensusSetFormHandler(w, req, nil)
+		s.initializeConsensusSetFormHandler(w, req, nil)
 	}
 }
 
-func initializeConsensusSetFormHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	message := "Consensus set was not found"
+func (s *Server) initializeConsensusSetFormHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	s.writePage(w, resources.InitializeConsensusSetPage{Message: consensusSetMessage(), CSRFToken: s.formCSRFToken(req)})
+}
+
+// consensusSetMessage returns why the consensus set has to be obtained.
+func consensusSetMessage() string {
 	if bootstrapper.LocalConsensusSize > 0 {
-		message = "Consensus set is out of date"
+		return "Consensus set is out of date"
 	}
-	html := strings.Replace(resources.InitializeConsensusSetForm(), "&CONSENSUS_MESSAGE;", message, -1)
-	writeStaticHTML(w, html, "")
+	return "Consensus set was not found"
 }
 
-func initializeBootstrapperHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+// importConsensusHandler bootstraps the consensus set from an archive that
+// was copied onto this machine.
+func (s *Server) importConsensusHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	path := strings.TrimSpace(req.FormValue("path"))
+	err := errors.New("enter the path of the consensus set archive")
+	if path != "" {
+		err = bootstrapper.Import(path)
+	}
+	if err != nil {
+		s.log.Warn("Unable to import the consensus set", logger.F("path", path), logger.Err(err))
+		s.writePage(w, resources.InitializeConsensusSetPage{
+			Message:     consensusSetMessage(),
+			ImportPath:  path,
+			ImportError: err.Error(),
+			CSRFToken:   s.formCSRFToken(req),
+		})
+		return
+	}
+	s.bootstrappingHandler(w, req, nil)
+}
+
+func (s *Server) initializeBootstrapperHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	bootstrapper.Initialize()
-	bootstrappingHandler(w, req, nil)
+	s.bootstrappingHandler(w, req, nil)
 }
 
-func skipBootstrapperHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+func (s *Server) skipBootstrapperHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	bootstrapper.Skip()
 	time.Sleep(50 * time.Millisecond)
 	consensusbuilder.Initialize()
-	buildingConsensusSetHandler(w, req, nil)
+	s.buildingConsensusSetHandler(w, req, nil)
 }
 
-func initializeConsensusBuilderHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+func (s *Server) initializeConsensusBuilderHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	bootstrapper.Skip()
 	time.Sleep(50 * time.Millisecond)
 	consensusbuilder.Initialize()
-	buildingConsensusSetHandler(w, req, nil)
+	s.buildingConsensusSetHandler(w, req, nil)
+}
+
+func (s *Server) pauseBootstrapperHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	bootstrapper.Pause()
+	s.bootstrappingHandler(w, req, nil)
+}
+
+func (s *Server) resumeBootstrapperHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	bootstrapper.Resume()
+	s.bootstrappingHandler(w, req, nil)
 }
 
-func bootstrappingHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	progress := bootstrapper.Progress()
-	html := strings.Replace(resources.BootstrappingHTML(), "&BOOTSTRAPPER_PROGRESS;", progress, -1)
-	writeStaticHTML(w, html, "")
+func (s *Server) bootstrappingHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	telemetry := bootstrapper.GetTelemetry()
+	s.writePage(w, resources.BootstrappingPage{
+		Progress:  telemetry.Progress,
+		Importing: bootstrapper.Importing(),
+		Paused:    telemetry.Paused,
+		Error:     telemetry.Error,
+		Phases:    s.startupPhaseViews(),
+		CSRFToken: s.formCSRFToken(req),
+	})
 }
 
-func buildingConsensusSetHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	progress := consensusbuilder.Progress()
-	html := strings.Replace(resources.ConsensusSetBuildingHTML(), "&CONSENSUS_BUILDER_PROGRESS;", progress, -1)
-	writeStaticHTML(w, html, "")
+func (s *Server) buildingConsensusSetHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	s.writePage(w, resources.ConsensusSetBuildingPage{Progress: consensusbuilder.Progress(), Phases: s.startupPhaseViews()})
 }
 
-func coldWalletHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+func (s *Server) coldWalletHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	var seed modules.Seed
 	// zero arguments: generate a seed
 	fastrand.Read(seed[:])
@@ -776,7 +853,7 @@ func coldWalletHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Pa
 	unlockHashStr := ""
 	if seedErr != nil {
 		seedStr = fmt.Sprintf("Unable to generate cold wallet seed: %v", seedErr)
-		fmt.Println(seedStr)
+		s.log.Error("Unable to generate cold wallet seed", logger.Err(seedErr))
 	} else {
 		_, pk := crypto.GenerateKeyPairDeterministic(crypto.HashAll(seed, 0))
 		unlockHash := types.UnlockConditions{
@@ -785,142 +862,135 @@ func coldWalletHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Pa
 		}.UnlockHash()
 		unlockHashStr = strings.ToUpper(fmt.Sprintf("%s", unlockHash))
 	}
-	html := resources.ColdWalletHTML()
-	html = strings.Replace(html, "&SEED;", seedStr, -1)
-	html = strings.Replace(html, "&UNLOCK_HASH;", unlockHashStr, -1)
-	writeStaticHTML(w, html, "")
+	s.writePage(w, resources.ColdWalletPage{Seed: seedStr, UnlockHash: unlockHashStr})
 }
 
-func expandMenuHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	sessionID := req.FormValue("session_id")
-	if sessionID == "" || !sessionIDExists(sessionID) {
+func (s *Server) expandMenuHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sessionID := requestSessionID(req)
+	if sessionID == "" || !s.sessionIDExists(sessionID) {
 		redirect(w, req, nil)
 		return
 	}
-	expandMenu(sessionID)
-	writeHTML(w, getCachedPage(sessionID), sessionID)
+	s.expandMenu(sessionID)
+	s.writeCachedPage(w, req, sessionID)
 }
 
-func collapseMenuHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	sessionID := req.FormValue("session_id")
-	if sessionID == "" || !sessionIDExists(sessionID) {
+func (s *Server) collapseMenuHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sessionID := requestSessionID(req)
+	if sessionID == "" || !s.sessionIDExists(sessionID) {
 		redirect(w, req, nil)
 		return
 	}
-	collapseMenu(sessionID)
-	writeHTML(w, getCachedPage(sessionID), sessionID)
+	s.collapseMenu(sessionID)
+	s.writeCachedPage(w, req, sessionID)
 }
 
-func scanningHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	sessionID := req.FormValue("session_id")
-	if sessionID == "" || !sessionIDExists(sessionID) {
+func (s *Server) scanningHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sessionID := requestSessionID(req)
+	if sessionID == "" || !s.sessionIDExists(sessionID) {
 		redirect(w, req, nil)
 		return
 	}
-	_, err := getWallet(sessionID)
+	_, err := s.getWallet(sessionID)
 	if err != nil {
 		msg := fmt.Sprintf("%v", err)
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
-	height, _, _ := blockHeightHelper(sessionID)
-	if height == "0" && status != "" {
-		title := "<font class='status &STATUS_COLOR;'>&STATUS;</font> WALLET"
-		form := resources.ScanningWalletForm()
-		writeForm(w, title, form, sessionID)
+	height, _, _ := s.blockHeightHelper(sessionID)
+	if height == "0" && s.getStatus(sessionID) != walletStateIdle {
+		s.writeScanning(w, sessionID)
 		return
 	}
-	if status != "" {
-		title := "<font class='status &STATUS_COLOR;'>&STATUS;</font> WALLET"
-		form := resources.ScanningWalletForm()
-		writeForm(w, title, form, sessionID)
+	if s.getStatus(sessionID) != walletStateIdle {
+		s.writeScanning(w, sessionID)
 		return
 	}
-	guiHandler(w, req, nil)
+	s.guiHandler(w, req, nil)
 }
 
-func setTxHistoyPage(w http.ResponseWriter, req *http.Request, resp httprouter.Params) {
-	sessionID := req.FormValue("session_id")
-	if sessionID == "" || !sessionIDExists(sessionID) {
+func (s *Server) setTxHistoyPage(w http.ResponseWriter, req *http.Request, resp httprouter.Params) {
+	sessionID := requestSessionID(req)
+	if sessionID == "" || !s.sessionIDExists(sessionID) {
 		redirect(w, req, nil)
 		return
 	}
 	page, _ := strconv.Atoi(req.FormValue("page"))
-	setTxHistoryPage(page, sessionID)
-	guiHandler(w, req, nil)
+	s.setTxHistoryPage(page, sessionID)
+	s.guiHandler(w, req, nil)
 }
 
-func guiHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+func (s *Server) guiHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	if failure, failed := s.startupFailure(); failed {
+		s.writeStartupFailure(w, req, failure)
+		return
+	}
 	for i := 0; i < 10; i++ {
-		if n.TransactionPool != nil {
+		if s.nodeLoaded() {
 			break
 		}
 		time.Sleep(25 * time.Millisecond)
 	}
-	if n.TransactionPool == nil {
-		writeStaticHTML(w, resources.StartingWalletForm(), "")
+	if !s.nodeLoaded() {
+		s.writePage(w, resources.StartingWalletPage{Phases: s.startupPhaseViews()})
 		return
 	}
-	sessionID := req.FormValue("session_id")
-	if sessionID == "" || !sessionIDExists(sessionID) {
-		writeStaticHTML(w, resources.InitializeWalletForm(), "")
+	sessionID := requestSessionID(req)
+	if sessionID == "" || !s.sessionIDExists(sessionID) {
+		s.writePage(w, resources.InitializeWalletPage{CSRFToken: s.formCSRFToken(req)})
 		return
 	}
-	wallet, err := getWallet(sessionID)
+	wallet, err := s.getWallet(sessionID)
 	if err != nil {
-		writeStaticHTML(w, resources.InitializeWalletForm(), "")
+		s.writePage(w, resources.InitializeWalletPage{CSRFToken: s.formCSRFToken(req)})
 		return
 	}
-	height, _, _ := blockHeightHelper(sessionID)
-	if height == "0" && status != "" {
-		title := "<font class='status &STATUS_COLOR;'>&STATUS;</font> WALLET"
-		form := resources.ScanningWalletForm()
-		writeForm(w, title, form, sessionID)
+	height, _, _ := s.blockHeightHelper(sessionID)
+	if height == "0" && s.getStatus(sessionID) != walletStateIdle {
+		s.writeScanning(w, sessionID)
 		return
 	}
-	if status != "" {
-		title := "<font class='status &STATUS_COLOR;'>&STATUS;</font> WALLET"
-		form := resources.ScanningWalletForm()
-		writeForm(w, title, form, sessionID)
+	if s.getStatus(sessionID) != walletStateIdle {
+		s.writeScanning(w, sessionID)
 		return
 	}
 	unlocked, err := wallet.Unlocked()
 	if err != nil {
 		msg := fmt.Sprintf("Unable to determine if wallet is unlocked: %v", err)
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
 	if unlocked {
-		writeWallet(w, wallet, sessionID)
+		s.writeWallet(w, wallet, sessionID)
 		return
 	}
-	closeWallet(sessionID)
+	s.logout(sessionID)
+	clearSessionCookie(w)
 	redirect(w, req, nil)
 }
 
-func writeWallet(w http.ResponseWriter, wallet modules.Wallet, sessionID string) {
-	transactionHistoryLines, pages, err := transactionHistoryHelper(wallet, sessionID)
+func (s *Server) writeWallet(w http.ResponseWriter, wallet modules.Wallet, sessionID string) {
+	transactionHistoryLines, pages, err := s.transactionHistoryHelper(wallet, sessionID)
 	if err != nil {
 		msg := fmt.Sprintf("Unable to generate transaction history: %v", err)
-		writeError(w, msg, sessionID)
+		s.writeError(w, msg)
 		return
 	}
-	html := resources.WalletHTMLTemplate()
-	html = strings.Replace(html, "&TRANSACTION_PORTAL;", resources.TransactionsHistoryHTMLTemplate(), -1)
-	html = strings.Replace(html, "&TRANSACTION_HISTORY_LINES;", transactionHistoryLines, -1)
-	options := ""
-	for i := 0; i < pages+1; i++ {
-		selected := ""
-		if i+1 == getTxHistoryPage(sessionID) {
-			selected = "selected"
-		}
-		options = fmt.Sprintf("<option %s value='%d'>%d</option>", selected, i+1, i+1) + options
+	csrfToken := s.csrfToken(sessionID)
+	for i := range transactionHistoryLines {
+		transactionHistoryLines[i].CSRFToken = csrfToken
+	}
+	history := resources.TransactionHistory{
+		CSRFToken: csrfToken,
+		Lines:     transactionHistoryLines,
+		PageCount: pages + 1,
 	}
-	isLastPage := getTxHistoryPage(sessionID) == pages+1
-	html = strings.Replace(html, "&TRANSACTION_HISTORY_PAGE;", options, -1)
-	html = strings.Replace(html, "&TRANSACTION_HISTORY_PAGES;", strconv.Itoa(pages+1), -1)
-	html = strings.Replace(html, "&IS_LAST_PAGE;", strconv.FormatBool(isLastPage), -1)
-	writeHTML(w, html, sessionID)
+	for i := pages + 1; i > 0; i-- {
+		selected := i == s.getTxHistoryPage(sessionID)
+		history.Pages = append(history.Pages, resources.TransactionHistoryPage{Number: i, Selected: selected})
+	}
+	history.IsLastPage = s.getTxHistoryPage(sessionID) == pages+1
+	s.writeHTML(w, resources.WalletPage{Portal: history}, sessionID)
 }
 
 func writeArray(w http.ResponseWriter, arr []string) {
@@ -929,71 +999,79 @@ func writeArray(w http.ResponseWriter, arr []string) {
 	fmt.Fprint(w, string(encjson))
 }
 
-func writeError(w http.ResponseWriter, msg string, sessionID string) {
-	html := resources.ErrorHTMLTemplate()
-	html = strings.Replace(html, "&POPUP_TITLE;", "ERROR", -1)
-	html = strings.Replace(html, "&POPUP_CONTENT;", msg, -1)
-	html = strings.Replace(html, "&POPUP_CLOSE;", resources.CloseAlertForm(), -1)
-	fmt.Println(msg)
-	writeStaticHTML(w, html, sessionID)
+func (s *Server) writeError(w http.ResponseWriter, msg string) {
+	s.log.Warn("Showing error page", logger.F("message", msg))
+	s.writePage(w, resources.ErrorPage{Title: "ERROR", Message: msg})
+}
+
+func (s *Server) writeMsg(w http.ResponseWriter, title string, msg string, sessionID string) {
+	page := resources.AlertPage{Title: title, Message: msg, Close: true}
+	s.writeHTML(w, page, sessionID)
 }
 
-func writeMsg(w http.ResponseWriter, title string, msg string, sessionID string) {
-	html := resources.AlertHTMLTemplate()
-	html = strings.Replace(html, "&POPUP_TITLE;", title, -1)
-	html = strings.Replace(html, "&POPUP_CONTENT;", msg, -1)
-	html = strings.Replace(html, "&POPUP_CLOSE;", resources.CloseAlertForm(), -1)
-	writeHTML(w, html, sessionID)
+func (s *Server) writeForm(w http.ResponseWriter, title string, form resources.Renderer, sessionID string) {
+	page := resources.AlertPage{Title: title, Form: form}
+	s.writeHTML(w, page, sessionID)
 }
 
-func writeForm(w http.ResponseWriter, title string, form string, sessionID string) {
-	html := resources.AlertHTMLTemplate()
-	html = strings.Replace(html, "&POPUP_TITLE;", title, -1)
-	html = strings.Replace(html, "&POPUP_CONTENT;", form, -1)
-	html = strings.Replace(html, "&POPUP_CLOSE;", "", -1)
-	writeHTML(w, html, sessionID)
+// writeScanning writes the page that is shown while a long running operation
+// is performed on the session's wallet.
+func (s *Server) writeScanning(w http.ResponseWriter, sessionID string) {
+	fmtHeight, fmtStatus, fmtStatCo := s.blockHeightHelper(sessionID)
+	form := resources.ScanningWalletForm{
+		CSRFToken:   s.csrfToken(sessionID),
+		Status:      fmtStatus,
+		StatusColor: fmtStatCo,
+		BlockHeight: fmtHeight,
+	}
+	page := resources.AlertPage{Title: "WALLET", ShowStatus: true, Form: form}
+	s.writeHTML(w, page, sessionID)
 }
 
-func writeStaticHTML(w http.ResponseWriter, html string, sessionID string) {
-	// add random data to links to act as a cache buster.
-	// must be done last in case a cache buster is added in from a template.
-	b := make([]byte, 16) //32 characters long
-	rand.Read(b)
-	cacheBuster := hex.EncodeToString(b)
-	html = strings.Replace(html, "&CACHE_BUSTER;", cacheBuster, -1)
-	html = strings.Replace(html, "&SESSION_ID;", sessionID, -1)
+// writePage renders the page into a buffer first so that a template error
+// results in an error response instead of half a page.
+func (s *Server) writePage(w http.ResponseWriter, page resources.Renderer) {
+	var buf bytes.Buffer
+	err := page.Render(&buf)
+	if err != nil {
+		s.log.Error("Unable to render page", logger.F("page", fmt.Sprintf("%T", page)), logger.Err(err))
+		http.Error(w, "unable to render page", http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	fmt.Fprint(w, html)
+	w.Write(buf.Bytes())
 }
 
-func writeHTML(w http.ResponseWriter, html string, sessionID string) {
-	if hasAlert(sessionID) {
-		writeError(w, popAlert(sessionID), sessionID)
+// writeCachedPage writes the session's last page again, or the main page when
+// there is none.
+func (s *Server) writeCachedPage(w http.ResponseWriter, req *http.Request, sessionID string) {
+	page := s.getCachedPage(sessionID)
+	if page == nil {
+		s.guiHandler(w, req, nil)
 		return
 	}
-	cachedPage(html, sessionID)
-	html = strings.Replace(html, "&WEB_WALLET_VERSION;", build.Version, -1)
-	html = strings.Replace(html, "&SPD_VERSION;", spdBuild.Version, -1)
-	session, _ := getSession(sessionID)
+	s.writeHTML(w, page, sessionID)
+}
+
+func (s *Server) writeHTML(w http.ResponseWriter, page resources.Page, sessionID string) {
+	if s.hasAlert(sessionID) {
+		s.writeError(w, s.popAlert(sessionID))
+		return
+	}
+	s.cachedPage(page, sessionID)
+	header := resources.Header{
+		WebWalletVersion: build.Version,
+		SPDVersion:       spdBuild.Version,
+		MenuCollapsed:    s.menuIsCollapsed(sessionID),
+		CSRFToken:        s.csrfToken(sessionID),
+	}
+	session, _ := s.getSession(sessionID)
 	if session != nil {
-		html = strings.Replace(html, "&SESSION_NAME;", session.name, -1)
-	}
-	fmtHeight, fmtStatus, fmtStatCo := blockHeightHelper(sessionID)
-	html = strings.Replace(html, "&STATUS_COLOR;", fmtStatCo, -1)
-	html = strings.Replace(html, "&STATUS;", fmtStatus, -1)
-	html = strings.Replace(html, "&BLOCK_HEIGHT;", fmtHeight, -1)
-	fmtScpBal, fmtUncBal, fmtSpfBal, fmtClmBal, fmtWhale := balancesHelper(sessionID)
-	html = strings.Replace(html, "&SCP_BALANCE;", fmtScpBal, -1)
-	html = strings.Replace(html, "&UNCONFIRMED_DELTA;", fmtUncBal, -1)
-	html = strings.Replace(html, "&SPF_BALANCE;", fmtSpfBal, -1)
-	html = strings.Replace(html, "&SCP_CLAIM_BALANCE;", fmtClmBal, -1)
-	html = strings.Replace(html, "&WHALE_SIZE;", fmtWhale, -1)
-	if menuIsCollapsed(sessionID) {
-		html = strings.Replace(html, "&MENU;", resources.CollapsedMenuForm(), -1)
-	} else {
-		html = strings.Replace(html, "&MENU;", resources.ExpandedMenuForm(), -1)
+		header.SessionName = session.getName()
 	}
-	writeStaticHTML(w, html, sessionID)
+	header.BlockHeight, header.Status, header.StatusColor = s.blockHeightHelper(sessionID)
+	header.SCPBalance, header.UnconfirmedDelta, header.SPFBalance, _, header.WhaleSize = s.balancesHelper(sessionID)
+	s.writePage(w, page.WithHeader(header))
 }
 
 func whaleHelper(scpBal float64) string {
@@ -1033,24 +1111,24 @@ func whaleHelper(scpBal float64) string {
 	return "🐳"
 }
 
-func balancesHelper(sessionID string) (string, string, string, string, string) {
+func (s *Server) balancesHelper(sessionID string) (string, string, string, string, string) {
 	fmtScpBal := "?"
 	fmtUncBal := "?"
 	fmtSpfBal := "?"
 	fmtClmBal := "?"
 	fmtWhale := "?"
-	wallet, _ := getWallet(sessionID)
+	wallet, _ := s.getWallet(sessionID)
 	if wallet == nil {
 		return fmtScpBal, fmtUncBal, fmtSpfBal, fmtClmBal, fmtWhale
 	}
 	unlocked, err := wallet.Unlocked()
 	if err != nil {
-		fmt.Printf("Unable to determine if wallet is unlocked: %v", err)
+		s.sessionLog(sessionID).Warn("Unable to determine if wallet is unlocked", logger.Err(err))
 	}
 	if unlocked {
 		scpBal, spfBal, scpClaimBal, err := wallet.ConfirmedBalance()
 		if err != nil {
-			fmt.Printf("Unable to obtain confirmed balance: %v", err)
+			s.sessionLog(sessionID).Warn("Unable to obtain confirmed balance", logger.Err(err))
 		} else {
 			scpBalFloat, _ := new(big.Rat).SetFrac(scpBal.Big(), types.ScPrimecoinPrecision.Big()).Float64()
 			scpClaimBalFloat, _ := new(big.Rat).SetFrac(scpClaimBal.Big(), types.ScPrimecoinPrecision.Big()).Float64()
@@ -1061,7 +1139,7 @@ func balancesHelper(sessionID string) (string, string, string, string, string) {
 		}
 		scpOut, scpIn, err := wallet.UnconfirmedBalance()
 		if err != nil {
-			fmt.Printf("Unable to obtain unconfirmed balance: %v", err)
+			s.sessionLog(sessionID).Warn("Unable to obtain unconfirmed balance", logger.Err(err))
 		} else {
 			scpInFloat, _ := new(big.Rat).SetFrac(scpIn.Big(), types.ScPrimecoinPrecision.Big()).Float64()
 			scpOutFloat, _ := new(big.Rat).SetFrac(scpOut.Big(), types.ScPrimecoinPrecision.Big()).Float64()
@@ -1071,55 +1149,56 @@ func balancesHelper(sessionID string) (string, string, string, string, string) {
 	return fmtScpBal, fmtUncBal, fmtSpfBal, fmtClmBal, fmtWhale
 }
 
-func blockHeightHelper(sessionID string) (string, string, string) {
+func (s *Server) blockHeightHelper(sessionID string) (string, string, string) {
 	fmtHeight := "?"
-	wallet, _ := getWallet(sessionID)
+	wallet, _ := s.getWallet(sessionID)
 	if wallet == nil {
 		return fmtHeight, "Offline", "red"
 	}
 	height, err := wallet.Height()
 	if err != nil {
-		fmt.Printf("Unable to obtain block height: %v", err)
+		s.sessionLog(sessionID).Warn("Unable to obtain block height", logger.Err(err))
 	} else {
 		fmtHeight = fmt.Sprintf("%d", height)
 	}
-	if status != "" {
-		return fmtHeight, status, "yellow"
+	if session, _ := s.getSession(sessionID); session != nil {
+		if state, _ := session.getState(); state != walletStateIdle {
+			csHeight := s.consensusHeight()
+			if err == nil && csHeight > 0 && height <= csHeight {
+				session.setProgress(int(uint64(height) * 100 / uint64(csHeight)))
+			}
+			return fmtHeight, state.String(), "yellow"
+		}
 	}
 	rescanning, err := wallet.Rescanning()
 	if err != nil {
-		fmt.Printf("Unable to determine if wallet is being scanned: %v", err)
+		s.sessionLog(sessionID).Warn("Unable to determine if wallet is being scanned", logger.Err(err))
 	}
 	if rescanning {
 		return fmtHeight, "Rescanning", "cyan"
 	}
-	synced := n.ConsensusSet.Synced()
+	synced := s.consensusSynced()
 	if synced {
 		return fmtHeight, "Synchronized", "blue"
 	}
 	return fmtHeight, "Synchronizing", "yellow"
 }
 
-func initializeSeedHelper(newPassword string, sessionID string) {
-	setStatus("Initializing")
+func (s *Server) initializeSeedHelper(newPassword string, sessionID string) {
 	msgPrefix := "Unable to initialize new wallet seed: "
-	wallet, err := getWallet(sessionID)
+	wallet, err := s.getWallet(sessionID)
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		setAlert(msg, sessionID)
-		if status == "Initializing" {
-			status = ""
-		}
+		s.setAlert(msg, sessionID)
+		s.setStatus(sessionID, walletStateIdle)
 		return
 	}
 	var encryptionKey crypto.CipherKey = crypto.NewWalletKey(crypto.HashObject(newPassword))
 	_, err = wallet.Encrypt(encryptionKey)
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		setAlert(msg, sessionID)
-		if status == "Initializing" {
-			status = ""
-		}
+		s.setAlert(msg, sessionID)
+		s.setStatus(sessionID, walletStateIdle)
 		return
 	}
 	potentialKeys, _ := encryptionKeys(newPassword)
@@ -1127,17 +1206,15 @@ func initializeSeedHelper(newPassword string, sessionID string) {
 		unlocked, err := wallet.Unlocked()
 		if err != nil {
 			msg := fmt.Sprintf("%s%v", msgPrefix, err)
-			setAlert(msg, sessionID)
-			if status == "Initializing" {
-				status = ""
-			}
+			s.setAlert(msg, sessionID)
+			s.setStatus(sessionID, walletStateIdle)
 			return
 		}
 		if !unlocked {
 			wallet.Unlock(key)
 		}
 	}
-	setStatus("")
+	s.setStatus(sessionID, walletStateIdle)
 }
 
 func isPasswordValid(wallet modules.Wallet, password string) (bool, error) {
@@ -1156,29 +1233,24 @@ func isPasswordValid(wallet modules.Wallet, password string) (bool, error) {
 	return false, err
 }
 
-func restoreSeedHelper(newPassword string, seed modules.Seed, sessionID string) {
-	setStatus("Restoring")
-	for !n.ConsensusSet.Synced() {
+func (s *Server) restoreSeedHelper(newPassword string, seed modules.Seed, sessionID string) {
+	for !s.consensusSynced() {
 		time.Sleep(25 * time.Millisecond)
 	}
 	msgPrefix := "Unable to restore new wallet seed: "
-	wallet, err := getWallet(sessionID)
+	wallet, err := s.getWallet(sessionID)
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		setAlert(msg, sessionID)
-		if status == "Restoring" {
-			status = ""
-		}
+		s.setAlert(msg, sessionID)
+		s.setStatus(sessionID, walletStateIdle)
 		return
 	}
 	var encryptionKey crypto.CipherKey = crypto.NewWalletKey(crypto.HashObject(newPassword))
 	err = wallet.InitFromSeed(encryptionKey, seed)
 	if err != nil {
 		msg := fmt.Sprintf("%s%v", msgPrefix, err)
-		setAlert(msg, sessionID)
-		if status == "Restoring" {
-			status = ""
-		}
+		s.setAlert(msg, sessionID)
+		s.setStatus(sessionID, walletStateIdle)
 		return
 	}
 	potentialKeys, _ := encryptionKeys(newPassword)
@@ -1186,121 +1258,122 @@ func restoreSeedHelper(newPassword string, seed modules.Seed, sessionID string)
 		unlocked, err := wallet.Unlocked()
 		if err != nil {
 			msg := fmt.Sprintf("%s%v", msgPrefix, err)
-			setAlert(msg, sessionID)
-			if status == "Restoring" {
-				status = ""
-			}
+			s.setAlert(msg, sessionID)
+			s.setStatus(sessionID, walletStateIdle)
 			return
 		}
 		if !unlocked {
 			wallet.Unlock(key)
 		}
 	}
-	setStatus("")
+	s.setStatus(sessionID, walletStateIdle)
 }
 
-func shutdownHelper(sessionID string) {
+func (s *Server) shutdownHelper(sessionID string) {
 	sleepDuration := 5000 * time.Millisecond
 	time.Sleep(sleepDuration)
-	if time.Now().After(heartbeat.Add(sleepDuration)) {
-		fmt.Println("Heartbeat expired.")
-		CloseAllWallets()
-		srv.Shutdown(context.Background())
+	if time.Now().After(s.lastHeartbeat().Add(sleepDuration)) {
+		s.log.Info("Heartbeat expired, shutting down")
+		s.requestShutdown()
 		return
 	}
-	session, err := getSession(sessionID)
+	session, err := s.getSession(sessionID)
 	if err != nil {
 		return //no session was found
 	}
-	if time.Now().After(session.heartbeat.Add(sleepDuration)) {
-		closeWallet(sessionID)
+	session.mu.Lock()
+	lastHeartbeat := session.heartbeat
+	session.mu.Unlock()
+	if time.Now().After(lastHeartbeat.Add(sleepDuration)) {
+		s.logout(sessionID)
 	}
 }
 
-func transactionExplorerHelper(txn modules.ProcessedTransaction) (string, error) {
+func transactionExplorerHelper(txn modules.ProcessedTransaction) resources.TransactionInfo {
 	unixTime, _ := strconv.ParseInt(fmt.Sprintf("%v", txn.ConfirmationTimestamp), 10, 64)
-	fmtTime := strings.ToUpper(time.Unix(unixTime, 0).Format("2006-01-02 15:04"))
-	fmtTxnID := strings.ToUpper(fmt.Sprintf("%v", txn.TransactionID))
-	fmtTxnType := strings.ToUpper(strings.Replace(fmt.Sprintf("%v", txn.TxType), "_", " ", -1))
-	fmtTxnBlock := strings.ToUpper(fmt.Sprintf("%v", txn.ConfirmationHeight))
-	html := resources.TransactionInfoTemplate()
-	html = strings.Replace(html, "&TXN_TYPE;", fmtTxnType, -1)
-	html = strings.Replace(html, "&TXN_ID;", fmtTxnID, -1)
-	html = strings.Replace(html, "&TXN_TIME;", fmtTime, -1)
-	html = strings.Replace(html, "&TXN_BLOCK;", fmtTxnBlock, -1)
-	inputs := ""
+	info := resources.TransactionInfo{
+		Type:  strings.ToUpper(strings.Replace(fmt.Sprintf("%v", txn.TxType), "_", " ", -1)),
+		ID:    strings.ToUpper(fmt.Sprintf("%v", txn.TransactionID)),
+		Time:  strings.ToUpper(time.Unix(unixTime, 0).Format("2006-01-02 15:04")),
+		Block: strings.ToUpper(fmt.Sprintf("%v", txn.ConfirmationHeight)),
+	}
 	for _, input := range txn.Inputs {
-		fmtValue := strings.ToUpper(fmt.Sprintf("%v", input.Value))
-		fmtAddress := strings.ToUpper(fmt.Sprintf("%v", input.RelatedAddress))
-		fmtFundType := strings.ToUpper(strings.Replace(fmt.Sprintf("%v", input.FundType), "_", " ", -1))
-		fmtFundType = strings.Replace(fmtFundType, "SIACOIN", "SCP", -1)
-		fmtFundType = strings.Replace(fmtFundType, "SIAFUND", "SPF", -1)
-		row := resources.TransactionInputTemplate()
-		row = strings.Replace(row, "&VALUE;", fmtValue, -1)
-		row = strings.Replace(row, "&ADDRESS;", fmtAddress, -1)
-		row = strings.Replace(row, "&FUND_TYPE;", fmtFundType, -1)
-		inputs = inputs + row
-	}
-	html = strings.Replace(html, "&TXN_INPUTS;", inputs, -1)
-	outputs := ""
+		info.Inputs = append(info.Inputs, transactionFundHelper(input.FundType, input.RelatedAddress, input.Value))
+	}
 	for _, output := range txn.Outputs {
-		fmtValue := strings.ToUpper(fmt.Sprintf("%v", output.Value))
-		fmtAddress := strings.ToUpper(fmt.Sprintf("%v", output.RelatedAddress))
-		fmtFundType := strings.ToUpper(strings.Replace(fmt.Sprintf("%v", output.FundType), "_", " ", -1))
-		fmtFundType = strings.Replace(fmtFundType, "SIACOIN", "SCP", -1)
-		fmtFundType = strings.Replace(fmtFundType, "SIAFUND", "SPF", -1)
-		row := resources.TransactionOutputTemplate()
-		row = strings.Replace(row, "&VALUE;", fmtValue, -1)
-		row = strings.Replace(row, "&ADDRESS;", fmtAddress, -1)
-		row = strings.Replace(row, "&FUND_TYPE;", fmtFundType, -1)
-		outputs = outputs + row
-	}
-	html = strings.Replace(html, "&TXN_OUTPUTS;", outputs, -1)
-	return html, nil
-}
-
-func transactionHistoryHelper(wallet modules.Wallet, sessionID string) (string, int, error) {
-	html := ""
-	page := getTxHistoryPage(sessionID)
+		info.Outputs = append(info.Outputs, transactionFundHelper(output.FundType, output.RelatedAddress, output.Value))
+	}
+	return info
+}
+
+// transactionFundHelper formats a transaction input or output for display.
+func transactionFundHelper(fundType types.Specifier, address types.UnlockHash, value types.Currency) resources.TransactionFund {
+	fmtFundType := strings.ToUpper(strings.Replace(fmt.Sprintf("%v", fundType), "_", " ", -1))
+	fmtFundType = strings.Replace(fmtFundType, "SIACOIN", "SCP", -1)
+	fmtFundType = strings.Replace(fmtFundType, "SIAFUND", "SPF", -1)
+	return resources.TransactionFund{
+		FundType: fmtFundType,
+		Address:  strings.ToUpper(fmt.Sprintf("%v", address)),
+		Value:    strings.ToUpper(fmt.Sprintf("%v", value)),
+	}
+}
+
+func (s *Server) transactionHistoryHelper(wallet modules.Wallet, sessionID string) ([]resources.TransactionHistoryLine, int, error) {
+	var lines []resources.TransactionHistoryLine
+	page := s.getTxHistoryPage(sessionID)
 	pageSize := 20
 	pageMin := (page - 1) * pageSize
 	pageMax := page * pageSize
 	count := 0
+	sts, err := s.summarizedTransactions(wallet)
+	if err != nil {
+		return nil, -1, err
+	}
+	for _, txn := range sts {
+		count++
+		if count >= pageMin && count < pageMax {
+			fmtAmount := txn.Scp
+			if txn.Spf != "" {
+				fmtAmount = fmtAmount + "; " + txn.Spf
+			}
+			lines = append(lines, resources.TransactionHistoryLine{
+				TransactionID:      txn.TxnID,
+				ShortTransactionID: txn.TxnID[0:16] + "..." + txn.TxnID[len(txn.TxnID)-16:],
+				Type:               txn.Type,
+				Time:               txn.Time,
+				Amount:             fmtAmount,
+				Confirmed:          txn.Confirmed,
+			})
+		}
+	}
+	return lines, count / pageSize, nil
+}
+
+// summarizedTransactions returns the wallet's confirmed and unconfirmed
+// transactions without the empty setup transactions.
+func (s *Server) summarizedTransactions(wallet modules.Wallet) ([]SummarizedTransaction, error) {
 	heightMin := 0
-	confirmedTxns, err := wallet.Transactions(types.BlockHeight(heightMin), n.ConsensusSet.Height())
+	height := s.consensusHeight()
+	confirmedTxns, err := wallet.Transactions(types.BlockHeight(heightMin), height)
 	if err != nil {
-		return "", -1, err
+		return nil, err
 	}
 	unconfirmedTxns, err := wallet.UnconfirmedTransactions()
 	if err != nil {
-		return "", -1, err
+		return nil, err
 	}
-	sts, err := ComputeSummarizedTransactions(append(confirmedTxns, unconfirmedTxns...), n.ConsensusSet.Height())
+	sts, err := ComputeSummarizedTransactions(append(confirmedTxns, unconfirmedTxns...), height)
 	if err != nil {
-		return "", -1, err
+		return nil, err
 	}
+	filtered := []SummarizedTransaction{}
 	for _, txn := range sts {
-		// Format transaction type
 		isSetup := txn.Type == "SETUP" && txn.Scp == fmt.Sprintf("%15.2f SCP", float64(0))
 		if !isSetup {
-			count++
-			if count >= pageMin && count < pageMax {
-				fmtAmount := txn.Scp
-				if txn.Spf != "" {
-					fmtAmount = fmtAmount + "; " + txn.Spf
-				}
-				row := resources.TransactionHistoryLineHTMLTemplate()
-				row = strings.Replace(row, "&TRANSACTION_ID;", txn.TxnID, -1)
-				row = strings.Replace(row, "&SHORT_TRANSACTION_ID;", txn.TxnID[0:16]+"..."+txn.TxnID[len(txn.TxnID)-16:], -1)
-				row = strings.Replace(row, "&TYPE;", txn.Type, -1)
-				row = strings.Replace(row, "&TIME;", txn.Time, -1)
-				row = strings.Replace(row, "&AMOUNT;", fmtAmount, -1)
-				row = strings.Replace(row, "&CONFIRMED;", txn.Confirmed, -1)
-				html = html + row
-			}
+			filtered = append(filtered, txn)
 		}
 	}
-	return html, count / pageSize, nil
+	return filtered, nil
 }
 
 // scanAddress scans a types.UnlockHash from a string.
@@ -0,0 +1,327 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"gitlab.com/scpcorp/ScPrime/node"
+
+	"gitlab.com/scpcorp/webwallet/modules/bootstrapper"
+)
+
+// metricsNamespace prefixes the names of the metrics.
+const metricsNamespace = "scp_webwallet_"
+
+// unmatchedRoute labels the requests that did not match a route, so that
+// arbitrary paths cannot grow the number of series.
+const unmatchedRoute = "unmatched"
+
+// Sources of unlock attempts.
+const (
+	unlockSourceGUI = "gui"
+	unlockSourceAPI = "api"
+)
+
+// latencyBuckets are the upper bounds in seconds of the request latency
+// histogram's buckets.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type (
+	// metrics collects the request and unlock counters that are exposed by
+	// the metrics endpoint. The gauges are read from the node when the
+	// endpoint is scraped. A nil *metrics collects nothing.
+	metrics struct {
+		mu             sync.Mutex
+		requests       map[requestLabels]uint64
+		latencies      map[routeLabels]*histogram
+		unlockFailures map[string]*uint64
+	}
+
+	// routeLabels identify the route a request was handled by.
+	routeLabels struct {
+		method string
+		route  string
+	}
+
+	// requestLabels identify the route a request was handled by and the
+	// status code it was answered with.
+	requestLabels struct {
+		routeLabels
+		code int
+	}
+
+	// histogram counts observations into cumulative buckets.
+	histogram struct {
+		counts []uint64
+		count  uint64
+		sum    float64
+	}
+
+	// statusRecorder records the status code written to a response.
+	statusRecorder struct {
+		http.ResponseWriter
+		code int
+	}
+)
+
+// WithMetrics enables the Prometheus metrics endpoint at /metrics. It is
+// disabled by default.
+func WithMetrics() Option {
+	return func(s *Server) {
+		s.metrics = newMetrics()
+	}
+}
+
+// newMetrics returns an empty collection of metrics.
+func newMetrics() *metrics {
+	return &metrics{
+		requests:  make(map[requestLabels]uint64),
+		latencies: make(map[routeLabels]*histogram),
+		unlockFailures: map[string]*uint64{
+			unlockSourceGUI: new(uint64),
+			unlockSourceAPI: new(uint64),
+		},
+	}
+}
+
+// observeRequest counts a request to the route and records its latency.
+func (m *metrics) observeRequest(labels routeLabels, code int, latency time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestLabels{routeLabels: labels, code: code}]++
+	h, ok := m.latencies[labels]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		m.latencies[labels] = h
+	}
+	h.observe(latency.Seconds())
+}
+
+// unlockFailed counts a failed attempt to unlock a wallet.
+func (m *metrics) unlockFailed(source string) {
+	if m == nil {
+		return
+	}
+	atomic.AddUint64(m.unlockFailures[source], 1)
+}
+
+// observe adds the value to the histogram.
+func (h *histogram) observe(v float64) {
+	for i, bound := range latencyBuckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Flush implements http.Flusher so that server sent events are still
+// streamed.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// metricsMiddleware counts the requests to each of the router's routes and
+// records their latency.
+func (s *Server) metricsMiddleware(router *httprouter.Router, next http.Handler) http.Handler {
+	if s.metrics == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(rec, req)
+		labels := routeLabels{route: unmatchedRoute}
+		if handle, params, _ := router.Lookup(req.Method, req.URL.Path); handle != nil {
+			labels = routeLabels{method: req.Method, route: routePattern(req.URL.Path, params)}
+		}
+		s.metrics.observeRequest(labels, rec.code, time.Since(start))
+	})
+}
+
+// routePattern returns the pattern of the route that matched the path by
+// replacing the values of the parameters with their names.
+func routePattern(path string, params httprouter.Params) string {
+	if len(params) == 0 {
+		return path
+	}
+	// A catch-all parameter is always the last one and holds the rest of the
+	// path including its leading slash.
+	last := params[len(params)-1]
+	if strings.HasPrefix(last.Value, "/") && strings.HasSuffix(path, last.Value) {
+		path = strings.TrimSuffix(path, last.Value) + "/*" + last.Key
+		params = params[:len(params)-1]
+	}
+	segments := strings.Split(path, "/")
+	for i := 0; i < len(segments) && len(params) > 0; i++ {
+		if segments[i] == params[0].Value {
+			segments[i] = ":" + params[0].Key
+			params = params[1:]
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// metricsHandler writes the metrics in the Prometheus text exposition format.
+func (s *Server) metricsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	var b strings.Builder
+	s.writeNodeMetrics(&b)
+	s.writeModuleMetrics(&b)
+	writeGauge(&b, "bootstrapper_downloaded_bytes", "Bytes of the consensus set downloaded by the bootstrapper.", float64(bootstrapper.BytesDownloaded()))
+	writeGauge(&b, "sessions", "Open sessions.", float64(s.sessions.len()))
+	writeGauge(&b, "open_wallets", "Sessions with a wallet attached.", float64(s.openWallets()))
+	s.metrics.write(&b)
+	io.WriteString(w, b.String())
+}
+
+// writeNodeMetrics writes the gauges of the node's modules that have been
+// loaded.
+func (s *Server) writeNodeMetrics(b *strings.Builder) {
+	s.withNode(func(node *node.Node) {
+		if node.ConsensusSet != nil {
+			writeGauge(b, "consensus_height", "Height of the consensus set.", float64(node.ConsensusSet.Height()))
+			writeGauge(b, "consensus_synced", "Whether the consensus set is synced (1) or not (0).", boolToFloat(node.ConsensusSet.Synced()))
+		}
+		if node.Gateway != nil {
+			writeGauge(b, "gateway_peers", "Peers the gateway is connected to.", float64(len(node.Gateway.Peers())))
+		}
+		if node.TransactionPool != nil {
+			writeGauge(b, "tpool_transactions", "Transactions in the transaction pool.", float64(len(node.TransactionPool.TransactionList())))
+		}
+	})
+}
+
+// writeModuleMetrics writes the load state of the node modules and how long
+// the loaded ones took to load.
+func (s *Server) writeModuleMetrics(b *strings.Builder) {
+	modules := s.modules.all()
+	states := []ModuleState{ModuleNotLoaded, ModuleLoading, ModuleLoaded, ModuleFailed}
+	writeHeader(b, "module_state", "gauge", "Load state of the node modules, 1 for the current state.")
+	for _, module := range modules {
+		for _, state := range states {
+			writeSample(b, "module_state", labels("module", module.Name, "state", string(state)), boolToFloat(module.State == state))
+		}
+	}
+	writeHeader(b, "module_load_seconds", "gauge", "Time the node modules took to load.")
+	for _, module := range modules {
+		if d, ok := s.modules.loadTime(module.Name); ok {
+			writeSample(b, "module_load_seconds", labels("module", module.Name), d.Seconds())
+		}
+	}
+}
+
+// write writes the request and unlock counters.
+func (m *metrics) write(b *strings.Builder) {
+	if m == nil {
+		return
+	}
+	writeHeader(b, "unlock_failures_total", "counter", "Failed attempts to unlock a wallet.")
+	for _, source := range []string{unlockSourceGUI, unlockSourceAPI} {
+		writeSample(b, "unlock_failures_total", labels("source", source), float64(atomic.LoadUint64(m.unlockFailures[source])))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	requests := make([]requestLabels, 0, len(m.requests))
+	for l := range m.requests {
+		requests = append(requests, l)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		if requests[i].routeLabels != requests[j].routeLabels {
+			return requests[i].routeLabels.less(requests[j].routeLabels)
+		}
+		return requests[i].code < requests[j].code
+	})
+	writeHeader(b, "http_requests_total", "counter", "HTTP requests by route and status code.")
+	for _, l := range requests {
+		writeSample(b, "http_requests_total", labels("method", l.method, "route", l.route, "code", strconv.Itoa(l.code)), float64(m.requests[l]))
+	}
+
+	routes := make([]routeLabels, 0, len(m.latencies))
+	for l := range m.latencies {
+		routes = append(routes, l)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].less(routes[j]) })
+	writeHeader(b, "http_request_duration_seconds", "histogram", "Latency of the HTTP requests by route.")
+	for _, l := range routes {
+		h := m.latencies[l]
+		for i, bound := range latencyBuckets {
+			le := strconv.FormatFloat(bound, 'g', -1, 64)
+			writeSample(b, "http_request_duration_seconds_bucket", labels("method", l.method, "route", l.route, "le", le), float64(h.counts[i]))
+		}
+		writeSample(b, "http_request_duration_seconds_bucket", labels("method", l.method, "route", l.route, "le", "+Inf"), float64(h.count))
+		writeSample(b, "http_request_duration_seconds_sum", labels("method", l.method, "route", l.route), h.sum)
+		writeSample(b, "http_request_duration_seconds_count", labels("method", l.method, "route", l.route), float64(h.count))
+	}
+}
+
+// less orders routes by route and then by method.
+func (l routeLabels) less(other routeLabels) bool {
+	if l.route != other.route {
+		return l.route < other.route
+	}
+	return l.method < other.method
+}
+
+// writeGauge writes a gauge without labels.
+func writeGauge(b *strings.Builder, name string, help string, value float64) {
+	writeHeader(b, name, "gauge", help)
+	writeSample(b, name, "", value)
+}
+
+// writeHeader writes the help and type lines of a metric.
+func writeHeader(b *strings.Builder, name string, kind string, help string) {
+	fmt.Fprintf(b, "# HELP %s%s %s\n# TYPE %s%s %s\n", metricsNamespace, name, help, metricsNamespace, name, kind)
+}
+
+// writeSample writes a sample of a metric.
+func writeSample(b *strings.Builder, name string, labels string, value float64) {
+	fmt.Fprintf(b, "%s%s%s %s\n", metricsNamespace, name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// labels formats the label name value pairs.
+func labels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// boolToFloat returns 1 for true and 0 for false.
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+
+	"gitlab.com/scpcorp/webwallet/logger"
+)
+
+// sessionCookieName is the name of the cookie that binds a browser to its
+// session.
+const sessionCookieName = "scp_webwallet_session"
+
+// legacySessionField is the form field that carried the session ID before the
+// session cookie was introduced. It is only read while legacy session fields
+// are allowed.
+const legacySessionField = "session_id"
+
+// csrfField is the form field that carries the session's CSRF token.
+const csrfField = "csrf_token"
+
+// csrfHeader is the HTTP header that scripts and API clients may use to supply
+// the session's CSRF token instead of the form field.
+const csrfHeader = "X-CSRF-Token"
+
+// csrfCookieName is the name of the cookie that holds the CSRF token of a
+// browser that has no session yet, such as one that is about to open a
+// wallet. Forms that are submitted without a session must carry the same
+// token as the cookie, which a page on another site cannot read.
+const csrfCookieName = "scp_webwallet_csrf"
+
+// apiPathPrefix prefixes the paths of the JSON API. API clients authenticate
+// with the session header only, so they are never given cookies.
+const apiPathPrefix = "/api/"
+
+// backgroundPaths are the paths that the GUI requests on its own while a page
+// is open. They do not count as the session being used, so that a wallet left
+// open in a browser tab still expires after being idle.
+var backgroundPaths = map[string]bool{
+	"/gui/balance":     true,
+	"/gui/blockHeight": true,
+	"/gui/events":      true,
+	"/gui/heartbeat":   true,
+}
+
+// contextKey is the type of the keys the middleware stores in a request's
+// context.
+type contextKey int
+
+const (
+	// sessionIDKey is the context key of the request's session ID.
+	sessionIDKey contextKey = iota
+	// csrfCookieKey is the context key of the browser's CSRF cookie token.
+	csrfCookieKey
+)
+
+// sessionMiddleware resolves the request's session ID from the session cookie,
+// the API session header or, during the migration period, the legacy form
+// field, marks the session as used unless the GUI sent the request in the
+// background and stores the session ID in the request's context for the
+// handlers. API requests are only authenticated by the session header, since a
+// cookie would be sent along with requests that another site makes the browser
+// send. Browsers are given a CSRF cookie for the forms they submit before they
+// have a session.
+func (s *Server) sessionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasPrefix(req.URL.Path, apiPathPrefix) {
+			sessionID := req.Header.Get(apiSessionHeader)
+			s.sessions.touch(sessionID)
+			ctx := context.WithValue(req.Context(), sessionIDKey, sessionID)
+			next.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		sessionID := ""
+		if cookie, err := req.Cookie(sessionCookieName); err == nil {
+			sessionID = cookie.Value
+		}
+		if sessionID == "" {
+			sessionID = req.Header.Get(apiSessionHeader)
+		}
+		if sessionID == "" && s.legacySessionField {
+			sessionID = req.FormValue(legacySessionField)
+			if sessionID != "" && s.sessionIDExists(sessionID) {
+				s.sessionLog(sessionID).Debug("Session ID was supplied in a form field, moving it to a cookie")
+				setSessionCookie(w, sessionID)
+			}
+		}
+		if !backgroundPaths[req.URL.Path] {
+			s.sessions.touch(sessionID)
+		}
+		ctx := context.WithValue(req.Context(), sessionIDKey, sessionID)
+		ctx = context.WithValue(ctx, csrfCookieKey, csrfCookieToken(w, req))
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// csrfCookieToken returns the token of the browser's CSRF cookie. A browser
+// without the cookie is given a new one.
+func csrfCookieToken(w http.ResponseWriter, req *http.Request) string {
+	if cookie, err := req.Cookie(csrfCookieName); err == nil && len(cookie.Value) == 32 {
+		return cookie.Value
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	token := hex.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     "/",
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token
+}
+
+// requestSessionID returns the session ID that the session middleware
+// resolved for the request.
+func requestSessionID(req *http.Request) string {
+	sessionID, _ := req.Context().Value(sessionIDKey).(string)
+	return sessionID
+}
+
+// setSessionCookie binds the browser to the session. The cookie is hidden from
+// scripts and is never sent on cross site requests. Browsers treat localhost
+// as a secure context, so the Secure flag does not prevent it from being sent
+// over plain HTTP to the local server.
+func setSessionCookie(w http.ResponseWriter, sessionID string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    sessionID,
+		Path:     "/",
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// clearSessionCookie removes the session cookie from the browser.
+func clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// hostMiddleware rejects requests that are not addressed to an allowed host
+// name. This stops web pages from reaching the wallet through DNS rebinding.
+func (s *Server) hostMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !s.hostAllowed(req.Host) {
+			s.log.Warn("Rejected request for a host that is not allowed", logger.F("path", req.URL.Path), logger.F("host", req.Host))
+			http.Error(w, "host is not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// hostAllowed returns true when the Host header names a loopback host or one
+// of the configured host names.
+func (s *Server) hostAllowed(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = strings.Trim(hostport, "[]")
+	}
+	for _, allowed := range loopbackHosts {
+		if strings.EqualFold(host, allowed) {
+			return true
+		}
+	}
+	for _, allowed := range s.allowedHosts {
+		if strings.EqualFold(host, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// originMiddleware rejects state changing requests that a browser sent from a
+// page on another origin. Requests without an Origin or Referer header do not
+// come from a browser page and are let through.
+func (s *Server) originMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, req)
+			return
+		}
+		source := req.Header.Get("Origin")
+		if source == "" {
+			source = req.Header.Get("Referer")
+		}
+		if source != "" && !sameOrigin(source, req.Host) {
+			s.log.Warn("Rejected cross origin request", logger.F("path", req.URL.Path), logger.F("origin", source))
+			http.Error(w, "cross origin requests are not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// sameOrigin returns true when the origin or referer URL points at the host
+// the request was sent to.
+func sameOrigin(source string, host string) bool {
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return u.Host == host
+}
+
+// csrfProtected wraps a handler so that it is only called when the request
+// carries the CSRF token of its session or, for forms that were rendered
+// before the browser had a session, the token of its CSRF cookie.
+func (s *Server) csrfProtected(handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		token := req.Header.Get(csrfHeader)
+		if token == "" {
+			token = req.FormValue(csrfField)
+		}
+		if !validCSRFToken(token, s.csrfToken(requestSessionID(req))) && !validCSRFToken(token, requestCSRFCookie(req)) {
+			s.sessionLog(requestSessionID(req)).Warn("Rejected request with an invalid CSRF token", logger.F("path", req.URL.Path))
+			http.Error(w, "invalid CSRF token", http.StatusForbidden)
+			return
+		}
+		handle(w, req, ps)
+	}
+}
+
+// validCSRFToken returns true when the token was supplied and matches the
+// expected token.
+func validCSRFToken(token string, expected string) bool {
+	return expected != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// requestCSRFCookie returns the token of the browser's CSRF cookie that the
+// session middleware resolved for the request.
+func requestCSRFCookie(req *http.Request) string {
+	token, _ := req.Context().Value(csrfCookieKey).(string)
+	return token
+}
+
+// formCSRFToken returns the CSRF token that the forms rendered for the request
+// carry: the token of the request's session or, when it has none, the token of
+// the browser's CSRF cookie.
+func (s *Server) formCSRFToken(req *http.Request) string {
+	if token := s.csrfToken(requestSessionID(req)); token != "" {
+		return token
+	}
+	return requestCSRFCookie(req)
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+
+	"gitlab.com/scpcorp/webwallet/logger"
+)
+
+// newTestServer returns a server that is not started and that discards its
+// log messages.
+func newTestServer(t *testing.T, opts ...Option) *Server {
+	opts = append([]Option{
+		WithDataDir(t.TempDir()),
+		WithLogger(logger.New(ioutil.Discard, logger.LevelError)),
+	}, opts...)
+	return New(opts...)
+}
+
+// okHandler responds with 200 OK.
+func okHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// TestCSRFProtected tests that a protected handler is only called when the
+// request carries the CSRF token of its session, or of the browser's CSRF
+// cookie when it has no session.
+func TestCSRFProtected(t *testing.T) {
+	s := newTestServer(t)
+	sessionID, err := s.addSessionID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSessionID, err := s.addSessionID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionToken := s.csrfToken(sessionID)
+	cookieToken := strings.Repeat("ab", 16)
+	handler := s.sessionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.csrfProtected(okHandler)(w, req, nil)
+	}))
+
+	tests := []struct {
+		name string
+		// session and cookie are the session and the CSRF cookie token the
+		// browser sends, if any.
+		session string
+		cookie  string
+		// form and header are the tokens supplied in the form field and in
+		// the header, if any.
+		form   string
+		header string
+		want   int
+	}{
+		{name: "session without token", session: sessionID, want: http.StatusForbidden},
+		{name: "session with wrong token", session: sessionID, form: strings.Repeat("0", 32), want: http.StatusForbidden},
+		{name: "session with another session's token", session: sessionID, form: s.csrfToken(otherSessionID), want: http.StatusForbidden},
+		{name: "session with token", session: sessionID, form: sessionToken, want: http.StatusOK},
+		{name: "session with token header", session: sessionID, header: sessionToken, want: http.StatusOK},
+		{name: "cookie without token", cookie: cookieToken, want: http.StatusForbidden},
+		{name: "cookie with wrong token", cookie: cookieToken, form: strings.Repeat("cd", 16), want: http.StatusForbidden},
+		{name: "cookie with token", cookie: cookieToken, form: cookieToken, want: http.StatusOK},
+		{name: "cookie token without cookie", form: cookieToken, want: http.StatusForbidden},
+		{name: "session token without session", form: sessionToken, want: http.StatusForbidden},
+		{name: "no session and no cookie", want: http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			form := url.Values{}
+			if test.form != "" {
+				form.Set(csrfField, test.form)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/gui/lockWallet", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if test.header != "" {
+				req.Header.Set(csrfHeader, test.header)
+			}
+			if test.session != "" {
+				req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: test.session})
+			}
+			if test.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: test.cookie})
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != test.want {
+				t.Fatalf("expected status %v, got %v", test.want, w.Code)
+			}
+		})
+	}
+}
+
+// TestCSRFCookie tests that a browser without a CSRF cookie is given one, and
+// that the forms rendered for it carry the cookie's token.
+func TestCSRFCookie(t *testing.T) {
+	s := newTestServer(t)
+	var formToken string
+	handler := s.sessionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		formToken = s.formCSRFToken(req)
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == csrfCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("no CSRF cookie was set")
+	}
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode {
+		t.Fatal("the CSRF cookie is readable by scripts or sent on cross site requests")
+	}
+	if formToken == "" || formToken != cookie.Value {
+		t.Fatalf("expected the forms to carry the cookie's token %q, got %q", cookie.Value, formToken)
+	}
+
+	// The API is never given a cookie.
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/wallet/status", nil))
+	if cookies := w.Result().Cookies(); len(cookies) != 0 {
+		t.Fatalf("expected no cookies for an API request, got %v", cookies)
+	}
+}
+
+// TestHostAndOriginMiddleware tests that requests are rejected when they are
+// addressed to a host name that is not allowed, or when a browser sent them
+// from a page on another origin.
+func TestHostAndOriginMiddleware(t *testing.T) {
+	s := newTestServer(t, WithAllowedHosts("wallet.lan"))
+	handler := s.hostMiddleware(s.originMiddleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	tests := []struct {
+		name    string
+		method  string
+		host    string
+		origin  string
+		referer string
+		want    int
+	}{
+		{name: "localhost", method: http.MethodGet, host: "localhost:4300", want: http.StatusOK},
+		{name: "localhost in capitals", method: http.MethodGet, host: "LOCALHOST:4300", want: http.StatusOK},
+		{name: "IPv4 loopback", method: http.MethodGet, host: "127.0.0.1:4300", want: http.StatusOK},
+		{name: "IPv6 loopback", method: http.MethodGet, host: "[::1]:4300", want: http.StatusOK},
+		{name: "allowed host without port", method: http.MethodGet, host: "wallet.lan", want: http.StatusOK},
+		{name: "disallowed host", method: http.MethodGet, host: "attacker.example:4300", want: http.StatusForbidden},
+		{name: "disallowed host posting", method: http.MethodPost, host: "attacker.example:4300", origin: "http://attacker.example:4300", want: http.StatusForbidden},
+		{name: "same origin", method: http.MethodPost, host: "localhost:4300", origin: "http://localhost:4300", want: http.StatusOK},
+		{name: "cross site origin", method: http.MethodPost, host: "localhost:4300", origin: "https://attacker.example", want: http.StatusForbidden},
+		{name: "other port origin", method: http.MethodPost, host: "localhost:4300", origin: "http://localhost:8080", want: http.StatusForbidden},
+		{name: "opaque origin", method: http.MethodPost, host: "localhost:4300", origin: "null", want: http.StatusForbidden},
+		{name: "cross site origin reading", method: http.MethodGet, host: "localhost:4300", origin: "https://attacker.example", want: http.StatusOK},
+		{name: "same origin referer only", method: http.MethodPost, host: "localhost:4300", referer: "http://localhost:4300/gui", want: http.StatusOK},
+		{name: "cross site referer only", method: http.MethodPost, host: "localhost:4300", referer: "https://attacker.example/page", want: http.StatusForbidden},
+		{name: "non-browser client", method: http.MethodPost, host: "localhost:4300", want: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "/gui/lockWallet", nil)
+			req.Host = test.host
+			if test.origin != "" {
+				req.Header.Set("Origin", test.origin)
+			}
+			if test.referer != "" {
+				req.Header.Set("Referer", test.referer)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != test.want {
+				t.Fatalf("expected status %v, got %v", test.want, w.Code)
+			}
+		})
+	}
+}
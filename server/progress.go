@@ -0,0 +1,189 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"gitlab.com/scpcorp/webwallet/resources"
+)
+
+// StartupPhase is a step of loading the node.
+type StartupPhase string
+
+// The startup phases in the order the daemon runs them.
+const (
+	PhaseBrowser          StartupPhase = "browser"
+	PhaseBootstrapper     StartupPhase = "bootstrapper"
+	PhaseGateway          StartupPhase = "gateway"
+	PhaseConsensusSet     StartupPhase = "consensus"
+	PhaseConsensusBuilder StartupPhase = "consensusbuilder"
+	PhaseTransactionPool  StartupPhase = "transactionpool"
+)
+
+// startupPhases are the startup phases in order.
+var startupPhases = []StartupPhase{
+	PhaseBrowser,
+	PhaseBootstrapper,
+	PhaseGateway,
+	PhaseConsensusSet,
+	PhaseConsensusBuilder,
+	PhaseTransactionPool,
+}
+
+// phaseTitles are the names of the startup phases as they are shown to the
+// user.
+var phaseTitles = map[StartupPhase]string{
+	PhaseBrowser:          "Configuring browser",
+	PhaseBootstrapper:     "Bootstrapping consensus",
+	PhaseGateway:          "Loading gateway",
+	PhaseConsensusSet:     "Loading consensus set",
+	PhaseConsensusBuilder: "Building consensus set",
+	PhaseTransactionPool:  "Loading transaction pool",
+}
+
+// PhaseState is the state of a startup phase.
+type PhaseState string
+
+const (
+	// PhasePending is the state of a phase that has not started.
+	PhasePending PhaseState = "pending"
+	// PhaseRunning is the state of the phase that is running.
+	PhaseRunning PhaseState = "running"
+	// PhaseDone is the state of a phase that has finished.
+	PhaseDone PhaseState = "done"
+	// PhaseFailed is the state of a phase that failed.
+	PhaseFailed PhaseState = "failed"
+)
+
+// PhaseProgress is the progress of a startup phase.
+type PhaseProgress struct {
+	Phase StartupPhase `json:"phase"`
+	Title string       `json:"title"`
+	State PhaseState   `json:"state"`
+	// Elapsed is how long the phase has been running, or how long it took
+	// once it has finished.
+	Elapsed        time.Duration `json:"-"`
+	ElapsedSeconds float64       `json:"elapsed_seconds"`
+	// Progress is the progress within the phase, such as the percentage of
+	// the consensus set that has been downloaded. It is empty for phases
+	// that do not report their progress.
+	Progress string `json:"progress,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// phaseRecord is the state of a startup phase as it is tracked.
+type phaseRecord struct {
+	state    PhaseState
+	started  time.Time
+	finished time.Time
+	progress func() string
+	// last is the progress when the phase finished.
+	last string
+	err  error
+}
+
+// startupProgress tracks the progress of the startup phases.
+type startupProgress struct {
+	mu     sync.Mutex
+	phases map[StartupPhase]*phaseRecord
+}
+
+// newStartupProgress returns the progress of a startup that has not begun.
+func newStartupProgress() *startupProgress {
+	sp := &startupProgress{phases: make(map[StartupPhase]*phaseRecord)}
+	for _, phase := range startupPhases {
+		sp.phases[phase] = &phaseRecord{state: PhasePending}
+	}
+	return sp
+}
+
+// StartPhase records that the startup phase is running. progress reports the
+// progress within the phase and may be nil.
+func (s *Server) StartPhase(phase StartupPhase, progress func() string) {
+	s.startup.mu.Lock()
+	defer s.startup.mu.Unlock()
+	s.startup.phases[phase] = &phaseRecord{
+		state:    PhaseRunning,
+		started:  time.Now(),
+		progress: progress,
+	}
+}
+
+// EndPhase records that the startup phase has finished, or failed with err.
+func (s *Server) EndPhase(phase StartupPhase, err error) {
+	s.startup.mu.Lock()
+	defer s.startup.mu.Unlock()
+	record := s.startup.phases[phase]
+	if record == nil || record.state != PhaseRunning {
+		return
+	}
+	record.state = PhaseDone
+	if err != nil {
+		record.state = PhaseFailed
+		record.err = err
+	}
+	record.finished = time.Now()
+	if record.progress != nil {
+		record.last = record.progress()
+	}
+}
+
+// StartupProgress returns the progress of every startup phase in order.
+func (s *Server) StartupProgress() []PhaseProgress {
+	now := time.Now()
+	s.startup.mu.Lock()
+	defer s.startup.mu.Unlock()
+	phases := make([]PhaseProgress, 0, len(startupPhases))
+	for _, phase := range startupPhases {
+		record := s.startup.phases[phase]
+		p := PhaseProgress{
+			Phase:    phase,
+			Title:    phaseTitles[phase],
+			State:    record.state,
+			Progress: record.last,
+		}
+		switch record.state {
+		case PhaseRunning:
+			p.Elapsed = now.Sub(record.started)
+			if record.progress != nil {
+				p.Progress = record.progress()
+			}
+		case PhaseDone, PhaseFailed:
+			p.Elapsed = record.finished.Sub(record.started)
+		}
+		if record.err != nil {
+			p.Error = record.err.Error()
+		}
+		p.ElapsedSeconds = p.Elapsed.Seconds()
+		phases = append(phases, p)
+	}
+	return phases
+}
+
+// startupPhaseViews returns the progress of the startup phases as it is
+// shown on the startup pages.
+func (s *Server) startupPhaseViews() []resources.StartupPhase {
+	phases := s.StartupProgress()
+	views := make([]resources.StartupPhase, 0, len(phases))
+	for _, p := range phases {
+		view := resources.StartupPhase{
+			Title:    p.Title,
+			State:    string(p.State),
+			Progress: p.Progress,
+		}
+		if p.State != PhasePending {
+			view.Elapsed = p.Elapsed.Round(time.Second).String()
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// startupProgressHandler returns the progress of the startup phases so that
+// the startup pages can update it without being reloaded.
+func (s *Server) startupProgressHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	writeJSON(w, http.StatusOK, s.StartupProgress())
+}
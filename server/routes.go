@@ -6,7 +6,7 @@ import (
 	"github.com/julienschmidt/httprouter"
 )
 
-func buildHTTPRoutes() *httprouter.Router {
+func (s *Server) buildHTTPRoutes() *httprouter.Router {
 	router := httprouter.New()
 	router.NotFound = http.HandlerFunc(notFoundHandler)
 	router.RedirectTrailingSlash = false
@@ -15,23 +15,52 @@ func buildHTTPRoutes() *httprouter.Router {
 	router.GET("/favicon.ico", faviconHandler)
 	router.GET("/gui/bootstrapperProgress", bootstrapperProgressHandler)
 	router.GET("/gui/consensusBuilderProgress", consensusBuilderProgressHandler)
+	router.GET("/gui/startupProgress", s.startupProgressHandler)
 	router.GET("/gui/logo.png", logoHandler)
 	router.GET("/gui/scripts.js", scriptHandler)
 	router.GET("/gui/styles.css", styleHandler)
 	router.GET("/gui/fonts/open-sans-v27-latin-regular.woff2", openSansLatinRegularWoff2Handler)
 	router.GET("/gui/fonts/open-sans-v27-latin-700.woff2", openSansLatin700Woff2Handler)
-	router.GET("/initializeColdWallet", coldWalletHandler)
-	router.POST("/gui/heartbeat", heartbeatHandler)
-	if n == nil {
-		router.GET("/", initializingNodeHandler)
-		router.GET("/initializeBootstrapper", initializeBootstrapperHandler)
-		router.GET("/skipBootstrapper", skipBootstrapperHandler)
-		router.GET("/initializeConsensusBuilder", initializeConsensusBuilderHandler)
+	router.GET("/initializeColdWallet", redirect)
+	router.POST("/initializeColdWallet", s.csrfProtected(s.coldWalletHandler))
+	router.POST("/gui/heartbeat", s.heartbeatHandler)
+
+	// Monitoring Calls
+	router.GET("/health", s.healthHandler)
+	router.GET("/status", s.statusHandler)
+	// Startup Calls
+	router.GET("/startup/retry", redirect)
+	router.GET("/startup/resetConsensus", redirect)
+	router.POST("/startup/retry", s.csrfProtected(s.startupRetryHandler))
+	router.POST("/startup/resetConsensus", s.csrfProtected(s.startupResetConsensusHandler))
+
+	if s.metrics != nil {
+		router.GET("/metrics", s.metricsHandler)
+	}
+
+	if !s.nodeAttached() {
+		router.GET("/", s.initializingNodeHandler)
+		router.GET("/initializeBootstrapper", redirect)
+		router.POST("/initializeBootstrapper", s.csrfProtected(s.initializeBootstrapperHandler))
+		router.GET("/skipBootstrapper", redirect)
+		router.POST("/skipBootstrapper", s.csrfProtected(s.skipBootstrapperHandler))
+		router.GET("/pauseBootstrapper", redirect)
+		router.POST("/pauseBootstrapper", s.csrfProtected(s.pauseBootstrapperHandler))
+		router.GET("/resumeBootstrapper", redirect)
+		router.POST("/resumeBootstrapper", s.csrfProtected(s.resumeBootstrapperHandler))
+		router.GET("/importConsensus", redirect)
+		router.POST("/importConsensus", s.csrfProtected(s.importConsensusHandler))
+		router.GET("/initializeConsensusBuilder", redirect)
+		router.POST("/initializeConsensusBuilder", s.csrfProtected(s.initializeConsensusBuilderHandler))
 		router.GET("/configureBrowser", redirect)
-		router.POST("/configureBrowser", configureBrowser)
+		router.POST("/configureBrowser", s.csrfProtected(s.configureBrowser))
+
+		// API Calls
+		router.GET("/api/v1/*path", apiNotReadyHandler)
+		router.POST("/api/v1/*path", apiNotReadyHandler)
 	} else {
-		router.GET("/", guiHandler)
-		router.GET("/gui", guiHandler)
+		router.GET("/", s.guiHandler)
+		router.GET("/gui", s.guiHandler)
 		router.GET("/gui/export", redirect)
 		router.GET("/gui/alert/changeLock", redirect)
 		router.GET("/gui/alert/initializeSeed", redirect)
@@ -53,30 +82,46 @@ func buildHTTPRoutes() *httprouter.Router {
 		router.GET("/gui/unlockWallet", redirect)
 		router.GET("/gui/unlockWalletForm", redirect)
 		router.GET("/gui/explorer", redirect)
-		router.POST("/gui", guiHandler)
-		router.POST("/gui/export", transactionHistoryCsvExport)
-		router.POST("/gui/alert/changeLock", alertChangeLockHandler)
-		router.POST("/gui/alert/initializeSeed", alertInitializeSeedHandler)
-		router.POST("/gui/alert/sendCoins", alertSendCoinsHandler)
-		router.POST("/gui/alert/receiveCoins", alertReceiveCoinsHandler)
-		router.POST("/gui/alert/recoverSeed", alertRecoverSeedHandler)
-		router.POST("/gui/alert/restoreFromSeed", alertRestoreFromSeedHandler)
-		router.POST("/gui/changeLock", changeLockHandler)
-		router.POST("/gui/collapseMenu", collapseMenuHandler)
-		router.POST("/gui/expandMenu", expandMenuHandler)
-		router.POST("/gui/explainWhale", explainWhaleHandler)
-		router.POST("/gui/initializeSeed", initializeSeedHandler)
-		router.POST("/gui/lockWallet", lockWalletHandler)
-		router.POST("/gui/privacy", privacyHandler)
-		router.POST("/gui/restoreSeed", restoreSeedHandler)
-		router.POST("/gui/scanning", scanningHandler)
-		router.POST("/gui/sendCoins", sendCoinsHandler)
-		router.POST("/gui/setTxHistoryPage", setTxHistoyPage)
-		router.POST("/gui/unlockWallet", unlockWalletHandler)
-		router.POST("/gui/unlockWalletForm", unlockWalletFormHandler)
-		router.POST("/gui/explorer", explorerHandler)
-		router.POST("/gui/balance", balanceHandler)
-		router.POST("/gui/blockHeight", blockHeightHandler)
+		router.POST("/gui", s.guiHandler)
+		router.POST("/gui/export", s.csrfProtected(s.transactionHistoryCsvExport))
+		router.POST("/gui/alert/changeLock", s.csrfProtected(s.alertChangeLockHandler))
+		router.POST("/gui/alert/initializeSeed", s.csrfProtected(s.alertInitializeSeedHandler))
+		router.POST("/gui/alert/sendCoins", s.csrfProtected(s.alertSendCoinsHandler))
+		router.POST("/gui/alert/receiveCoins", s.csrfProtected(s.alertReceiveCoinsHandler))
+		router.POST("/gui/alert/recoverSeed", s.csrfProtected(s.alertRecoverSeedHandler))
+		router.POST("/gui/alert/restoreFromSeed", s.csrfProtected(s.alertRestoreFromSeedHandler))
+		router.POST("/gui/changeLock", s.csrfProtected(s.changeLockHandler))
+		router.POST("/gui/collapseMenu", s.csrfProtected(s.collapseMenuHandler))
+		router.POST("/gui/expandMenu", s.csrfProtected(s.expandMenuHandler))
+		router.POST("/gui/explainWhale", s.csrfProtected(s.explainWhaleHandler))
+		router.POST("/gui/initializeSeed", s.csrfProtected(s.initializeSeedHandler))
+		router.POST("/gui/lockWallet", s.csrfProtected(s.lockWalletHandler))
+		router.POST("/gui/privacy", s.csrfProtected(s.privacyHandler))
+		router.POST("/gui/restoreSeed", s.csrfProtected(s.restoreSeedHandler))
+		router.POST("/gui/scanning", s.csrfProtected(s.scanningHandler))
+		router.POST("/gui/sendCoins", s.csrfProtected(s.sendCoinsHandler))
+		router.POST("/gui/setTxHistoryPage", s.csrfProtected(s.setTxHistoyPage))
+		router.POST("/gui/unlockWallet", s.csrfProtected(s.unlockWalletHandler))
+		router.POST("/gui/unlockWalletForm", s.csrfProtected(s.unlockWalletFormHandler))
+		router.POST("/gui/explorer", s.csrfProtected(s.explorerHandler))
+		router.POST("/gui/balance", s.balanceHandler)
+		router.POST("/gui/blockHeight", s.blockHeightHandler)
+		router.GET("/gui/events", s.eventsHandler)
+
+		// API Calls
+		router.POST("/api/v1/wallet/open", s.apiOpenWalletHandler)
+		router.POST("/api/v1/wallet/create", s.apiCreateWalletHandler)
+		router.POST("/api/v1/wallet/restore", s.apiRestoreWalletHandler)
+		router.POST("/api/v1/wallet/lock", s.apiLockWalletHandler)
+		router.POST("/api/v1/logout", s.apiLogoutHandler)
+		router.GET("/api/v1/wallet/balance", s.apiBalanceHandler)
+		router.GET("/api/v1/wallet/status", s.apiStatusHandler)
+		router.GET("/api/v1/wallet/address", s.apiAddressHandler)
+		router.GET("/api/v1/wallet/transactions", s.apiTransactionsHandler)
+		router.GET("/api/v1/wallet/transactions/:id", s.apiTransactionHandler)
+		router.POST("/api/v1/wallet/send", s.apiSendHandler)
+		router.GET("/api/v1/wallet/seed", s.apiSeedHandler)
+		router.POST("/api/v1/wallet/password", s.apiChangePasswordHandler)
 	}
 	return router
 }
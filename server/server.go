@@ -1,13 +1,15 @@
 package server
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"context"
 	checkErrors "errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,316 +18,606 @@ import (
 	"gitlab.com/scpcorp/ScPrime/modules"
 	"gitlab.com/scpcorp/ScPrime/modules/wallet"
 	"gitlab.com/scpcorp/ScPrime/node"
+	"gitlab.com/scpcorp/ScPrime/types"
+
+	"gitlab.com/scpcorp/webwallet/build"
+	"gitlab.com/scpcorp/webwallet/logger"
 )
 
+// Server is the web wallet's HTTP server. It owns its router, its sessions and
+// the node that wallets are attached to, so several servers can run in one
+// process.
+type Server struct {
+	addr     string
+	log      logger.Logger
+	srv      *http.Server
+	router   http.Handler
+	routerMu sync.RWMutex
+	sessions *sessionStore
+	updates  *updateNotifier
+	modules  *moduleStates
+	startup  *startupProgress
+	metrics  *metrics
+	waitCh   chan struct{}
+	stopCh   chan struct{}
+	stopOnce sync.Once
+
+	// node is the node that wallets are attached to and params are the
+	// parameters it was loaded with. They are nil until the node has been
+	// attached. nodeMu guards them, and the node's modules, because the node
+	// is attached and its modules are loaded and closed while requests are
+	// being served. released is true while the node is detached so that its
+	// modules can be loaded again.
+	node     *node.Node
+	params   *node.NodeParams
+	released bool
+	nodeMu   sync.RWMutex
+
+	// heartbeat is when the GUI last sent a heartbeat.
+	heartbeat   time.Time
+	heartbeatMu sync.Mutex
+
+	// shutdownCh is closed when the server asks to be shut down.
+	shutdownCh   chan struct{}
+	shutdownOnce sync.Once
+	// background tracks the wallet operations that run after the request
+	// that started them has returned.
+	background sync.WaitGroup
+
+	// startupActions receives the action the user chose after a node
+	// module failed to load.
+	startupActions chan StartupAction
+
+	// listener is a listening socket that was opened for the server, for
+	// example by systemd socket activation. The server listens on addr when
+	// it is nil.
+	listener net.Listener
+	// listenAddr is the address the server is listening on once it has
+	// started.
+	listenAddr net.Addr
+	// portFallback makes the server listen on a free port when it is unable
+	// to listen on addr.
+	portFallback bool
+	// dataDir is the web wallet's data directory.
+	dataDir string
+	// allowedHosts are the host names, besides the loopback names, that
+	// requests may be addressed to.
+	allowedHosts []string
+	// external allows the server to listen on interfaces other than
+	// loopback.
+	external bool
+	// headless keeps the server and its wallets running when the GUI stops
+	// sending heartbeats.
+	headless bool
+	// legacySessionField allows the session ID to be supplied in the
+	// session_id form field while pages from older versions are still open.
+	legacySessionField bool
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// DefaultAddress is the address the server listens on when none is supplied.
+const DefaultAddress = "127.0.0.1:4300"
+
 var (
-	n         *node.Node
-	nParams   *node.NodeParams
-	srv       *http.Server
-	status    string
-	heartbeat time.Time
-	sessions  []*Session
-	waitCh    chan struct{}
+	// errExternalAddress is returned when the server is asked to listen on an
+	// interface other than loopback without opting in.
+	errExternalAddress = errors.New("listening on interfaces other than loopback must be explicitly enabled")
+
+	// errNodeNotLoaded is returned when a wallet is opened before the node
+	// has loaded the modules it needs, or while they are loaded again.
+	errNodeNotLoaded = errors.New("the node is still loading")
+
+	// loopbackHosts are the host names that requests may always be addressed
+	// to.
+	loopbackHosts = []string{"localhost", "127.0.0.1", "::1"}
 )
 
-// Session is a struct that tracks session settings
-type Session struct {
-	id            string
-	alert         string
-	collapseMenu  bool
-	txHistoryPage int
-	cachedPage    string
-	wallet        modules.Wallet
-	name          string
-	heartbeat     time.Time
-}
-
-// StartHTTPServer starts the HTTP server to serve the GUI.
-func StartHTTPServer() {
-	wg := &sync.WaitGroup{}
-	wg.Add(1)
-	srv = &http.Server{Addr: ":4300", Handler: buildHTTPRoutes()}
-	go func() {
-		defer wg.Done()
-		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
-			fmt.Printf("Unable to start server: %v\n", err)
-			srv = nil
-		}
-	}()
-	waitCh = make(chan struct{})
-	go func() {
-		wg.Wait()
-		close(waitCh)
-	}()
+// WithAddress sets the address the server listens on.
+func WithAddress(addr string) Option {
+	return func(s *Server) {
+		s.addr = addr
+	}
 }
 
-// IsRunning returns true when the server is running
-func IsRunning() bool {
-	if srv == nil {
-		return false
-	}
-	for i := 0; i < 20; i++ {
-		time.Sleep(5 * time.Millisecond)
-		if srv == nil {
-			return false
-		}
+// WithListener makes the server serve on an already listening socket instead
+// of listening on its address.
+func WithListener(listener net.Listener) Option {
+	return func(s *Server) {
+		s.listener = listener
 	}
-	return srv != nil
 }
 
-// Wait returns the servers wait channel
-func Wait() chan struct{} {
-	return waitCh
+// WithPortFallback makes the server listen on a free port of the same
+// interface when it is unable to listen on its address, for example because
+// another program uses the port.
+func WithPortFallback() Option {
+	return func(s *Server) {
+		s.portFallback = true
+	}
 }
 
-// AttachNode attaches the node to the HTTP server.
-func AttachNode(node *node.Node, params *node.NodeParams) {
-	n = node
-	nParams = params
-	if srv != nil {
-		srv.Handler = buildHTTPRoutes()
+// WithNode attaches an already loaded node to the server.
+func WithNode(node *node.Node, params *node.NodeParams) Option {
+	return func(s *Server) {
+		s.node = node
+		s.params = params
 	}
 }
 
-// newWallet attaches a newly created wallet module to the session.
-func newWallet(walletDirName string, sessionID string) (modules.Wallet, error) {
-	loadStart := time.Now()
-	walletDeps := nParams.WalletDeps
-	if walletDeps == nil {
-		walletDeps = modules.ProdDependencies
+// WithDataDir sets the web wallet's data directory.
+func WithDataDir(dir string) Option {
+	return func(s *Server) {
+		s.dataDir = dir
 	}
-	fmt.Printf("Loading wallet...")
-	walletDir := filepath.Join(n.Dir, "wallets", walletDirName)
-	_, err := os.Stat(walletDir)
-	if err == nil {
-		return nil, fmt.Errorf("%s already exists", walletDirName)
-	}
-	cs := n.ConsensusSet
-	tp := n.TransactionPool
-	session, err := getSession(sessionID)
-	if err != nil {
-		return nil, err
+}
+
+// WithSessionLimits sets how long a session may be idle, how long a session
+// may live in total and how many sessions may be open at once.
+func WithSessionLimits(idleTimeout time.Duration, maxAge time.Duration, maxSessions int) Option {
+	return func(s *Server) {
+		s.sessions = newSessionStore(idleTimeout, maxAge, maxSessions)
 	}
-	if session.wallet != nil {
-		errors.New("session already has a wallet loaded")
+}
+
+// WithAllowedHosts adds host names that requests may be addressed to besides
+// localhost, 127.0.0.1 and ::1.
+func WithAllowedHosts(hosts ...string) Option {
+	return func(s *Server) {
+		s.allowedHosts = append(s.allowedHosts, hosts...)
 	}
-	w, err := wallet.NewCustomWallet(cs, tp, walletDir, walletDeps)
-	if err != nil {
-		return nil, err
+}
+
+// WithExternalInterfaces allows the server to listen on interfaces other than
+// loopback, exposing the wallet to other hosts on the network.
+func WithExternalInterfaces() Option {
+	return func(s *Server) {
+		s.external = true
 	}
-	session.wallet = w
-	session.name = walletDirName
-	fmt.Println(" done in", time.Since(loadStart).Seconds(), "seconds.")
-	return w, nil
 }
 
-// existingWallet attaches an existing wallet module to the session.
-func existingWallet(walletDirName string, sessionID string) (modules.Wallet, error) {
-	loadStart := time.Now()
-	walletDeps := nParams.WalletDeps
-	if walletDeps == nil {
-		walletDeps = modules.ProdDependencies
+// WithHeadless keeps the server running and its wallets loaded when the
+// browser is closed. Without it the server shuts down shortly after the last
+// heartbeat from the GUI.
+func WithHeadless() Option {
+	return func(s *Server) {
+		s.headless = true
 	}
-	fmt.Printf("Loading wallet...")
-	walletDir := filepath.Join(n.Dir, "wallets", walletDirName)
-	_, err := os.Stat(walletDir)
-	if checkErrors.Is(err, os.ErrNotExist) {
-		return nil, fmt.Errorf("%s does not exist", walletDirName)
+}
+
+// WithLegacySessionField sets whether the session ID is still accepted from
+// the session_id form field. It is accepted by default during the migration to
+// the session cookie.
+func WithLegacySessionField(allow bool) Option {
+	return func(s *Server) {
+		s.legacySessionField = allow
 	}
-	cs := n.ConsensusSet
-	tp := n.TransactionPool
-	session, err := getSession(sessionID)
-	if err != nil {
-		return nil, err
+}
+
+// WithLogger sets the logger the server writes its messages to.
+func WithLogger(log logger.Logger) Option {
+	return func(s *Server) {
+		s.log = log
 	}
-	if session.wallet != nil {
-		errors.New("session already has a wallet loaded")
+}
+
+// New returns a new Server configured with the supplied options.
+func New(opts ...Option) *Server {
+	s := &Server{
+		addr:     DefaultAddress,
+		dataDir:  build.ScPrimeWebWalletDir(),
+		log:      logger.New(os.Stdout, logger.LevelInfo),
+		sessions: newSessionStore(defaultSessionIdleTimeout, defaultSessionMaxAge, defaultMaxSessions),
+		updates:  newUpdateNotifier(),
+		modules:  newModuleStates(),
+		startup:  newStartupProgress(),
+		waitCh:   make(chan struct{}),
+		stopCh:   make(chan struct{}),
+
+		shutdownCh:         make(chan struct{}),
+		startupActions:     make(chan StartupAction, 1),
+		legacySessionField: true,
 	}
-	w, err := wallet.NewCustomWallet(cs, tp, walletDir, walletDeps)
-	if err != nil {
-		return nil, err
+	for _, opt := range opts {
+		opt(s)
 	}
-	session.wallet = w
-	session.name = walletDirName
-	fmt.Println(" done in", time.Since(loadStart).Seconds(), "seconds.")
-	return w, nil
+	s.router = s.buildHandler()
+	s.markLoadedModules()
+	s.subscribeNode()
+	return s
 }
 
-// closeWallet closes the wallet and detaches it from the node.
-func closeWallet(sessionID string) error {
-	session, err := getSession(sessionID)
-	if err != nil {
-		return err
+// ServeHTTP implements http.Handler so that the server can be mounted inside
+// another HTTP server.
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.routerMu.RLock()
+	router := s.router
+	s.routerMu.RUnlock()
+	router.ServeHTTP(w, req)
+}
+
+// buildHandler builds the server's routes and wraps them in the middleware
+// that every request passes through.
+func (s *Server) buildHandler() http.Handler {
+	router := s.buildHTTPRoutes()
+	return s.metricsMiddleware(router, s.hostMiddleware(s.originMiddleware(s.sessionMiddleware(router))))
+}
+
+// Start starts the HTTP server to serve the GUI. It returns an error when the
+// server is unable to listen on its address.
+func (s *Server) Start() error {
+	listener := s.listener
+	addr := s.addr
+	if listener != nil {
+		addr = listener.Addr().String()
 	}
-	wallet := session.wallet
-	if wallet != nil {
-		session.wallet = nil
-		session.name = ""
-		fmt.Println("Closing wallet...")
-		err = wallet.Close()
+	// Unix sockets can only be reached from the local host.
+	local := isLoopbackAddress(addr) || (listener != nil && listener.Addr().Network() == "unix")
+	if !s.external && !local {
+		if listener != nil {
+			listener.Close()
+		}
+		close(s.waitCh)
+		return fmt.Errorf("%s: %w", addr, errExternalAddress)
+	}
+	if listener == nil {
+		var err error
+		listener, err = net.Listen("tcp", addr)
+		if err != nil && s.portFallback {
+			s.log.Warn("Unable to listen on the address, listening on a free port instead", logger.F("address", addr), logger.Err(err))
+			host, _, _ := net.SplitHostPort(addr)
+			listener, err = net.Listen("tcp", net.JoinHostPort(host, "0"))
+		}
 		if err != nil {
+			close(s.waitCh)
 			return err
 		}
 	}
+	s.listenAddr = listener.Addr()
+	s.srv = &http.Server{Handler: s}
+	go func() {
+		defer close(s.waitCh)
+		if err := s.srv.Serve(listener); err != http.ErrServerClosed {
+			s.log.Error("Unable to serve the GUI", logger.Err(err))
+		}
+	}()
+	go s.threadedPruneSessions()
 	return nil
 }
 
-// CloseAllWallets closes all wallets and detaches them from the node.
-func CloseAllWallets() (err error) {
-	for _, session := range sessions {
-		wallet := session.wallet
-		if wallet != nil {
-			session.wallet = nil
-			session.name = ""
-			fmt.Println("Closing wallet...")
-			err = errors.Compose(wallet.Close())
-		}
+// URL returns the URL the GUI is served at. It is empty before the server has
+// started and when the server listens on a unix socket.
+func (s *Server) URL() string {
+	addr, ok := s.listenAddr.(*net.TCPAddr)
+	if !ok {
+		return ""
 	}
-	return err
+	// Browsers reach every interface and 127.0.0.1 through localhost, which
+	// is the name the GUI has always been opened at.
+	host := addr.IP.String()
+	if addr.IP.IsUnspecified() || addr.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(addr.Port))
 }
 
-func getWallet(sessionID string) (modules.Wallet, error) {
-	session, err := getSession(sessionID)
+// isLoopbackAddress returns true when the address only listens on a loopback
+// interface.
+func isLoopbackAddress(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
 	if err != nil {
-		return nil, err
-	} else if session.wallet == nil {
-		return nil, errors.New("no wallet is attached to the session")
+		return false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
 	}
-	return session.wallet, nil
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
 }
 
-// updateHeartbeat updates and returns the heartbeat time.
-func updateHeartbeat(sessionID string) time.Time {
-	heartbeat = time.Now()
-	session, _ := getSession(sessionID)
-	if session != nil {
-		session.heartbeat = time.Now()
+// Shutdown gracefully shuts down the HTTP server. It stops accepting requests
+// and waits until the requests in flight and the wallet operations they
+// started have finished, or until ctx expires. The wallets are left open.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+		s.unsubscribeNode()
+	})
+	var err error
+	if s.srv != nil {
+		err = s.srv.Shutdown(ctx)
 	}
-	return heartbeat
+	done := make(chan struct{})
+	go func() {
+		s.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = errors.Compose(err, fmt.Errorf("wallet operations are still running: %w", ctx.Err()))
+	}
+	return err
 }
 
-// setStatus sets the status.
-func setStatus(s string) {
-	status = s
+// ShutdownRequested returns a channel that is closed when the server asks to
+// be shut down, which it does when the GUI has been closed. The server keeps
+// running until Shutdown is called.
+func (s *Server) ShutdownRequested() <-chan struct{} {
+	return s.shutdownCh
 }
 
-// addSessionId adds a new session ID to memory.
-func addSessionID() string {
-	b := make([]byte, 16) //32 characters long
-	rand.Read(b)
-	session := &Session{}
-	session.id = hex.EncodeToString(b)
-	session.collapseMenu = true
-	session.txHistoryPage = 1
-	session.cachedPage = ""
-	sessions = append(sessions, session)
-	return session.id
+// requestShutdown asks the owner of the server to shut it down.
+func (s *Server) requestShutdown() {
+	s.shutdownOnce.Do(func() {
+		close(s.shutdownCh)
+	})
 }
 
-// getSession returns the session.
-func getSession(sessionID string) (*Session, error) {
-	for _, session := range sessions {
-		if session.id == sessionID {
-			return session, nil
-		}
+// goBackground runs a wallet operation in the background so that the request
+// that started it can return. Shutdown waits for it to finish.
+func (s *Server) goBackground(operation func()) {
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		operation()
+	}()
+}
+
+// Wait returns a channel that is closed once the server has stopped.
+func (s *Server) Wait() <-chan struct{} {
+	return s.waitCh
+}
+
+// AttachNode attaches the node to the HTTP server.
+func (s *Server) AttachNode(node *node.Node, params *node.NodeParams) {
+	s.nodeMu.Lock()
+	s.node = node
+	s.params = params
+	s.released = false
+	s.nodeMu.Unlock()
+	s.rebuildRouter()
+	s.subscribeNode()
+}
+
+// UpdateNode calls update while no request is using the attached node's
+// modules. The daemon sets the node's modules through it, since they are
+// loaded while requests are being served.
+func (s *Server) UpdateNode(update func()) {
+	s.nodeMu.Lock()
+	defer s.nodeMu.Unlock()
+	update()
+}
+
+// detachNode detaches the node from the server and stops following its
+// consensus set and transaction pool. The routes that need a node are not
+// served until it is attached again.
+func (s *Server) detachNode() {
+	// Holding subscribeMu keeps the node from being subscribed to again
+	// before it has been detached.
+	s.updates.subscribeMu.Lock()
+	s.nodeMu.Lock()
+	node := s.node
+	s.node = nil
+	s.released = node != nil
+	s.nodeMu.Unlock()
+	if node != nil {
+		s.removeSubscriptions(node)
 	}
-	return nil, errors.New("session ID was not found")
+	s.updates.subscribeMu.Unlock()
+	s.rebuildRouter()
 }
 
-// sessionIDExists returns true when the supplied session ID exists in memory.
-func sessionIDExists(sessionID string) bool {
-	session, _ := getSession(sessionID)
-	return session != nil
+// rebuildRouter replaces the routes with the ones that match whether a node
+// is attached.
+func (s *Server) rebuildRouter() {
+	router := s.buildHandler()
+	s.routerMu.Lock()
+	s.router = router
+	s.routerMu.Unlock()
 }
 
-// setAlert sets an alert on the session.
-func setAlert(alert string, sessionID string) {
-	session, _ := getSession(sessionID)
-	if session != nil {
-		session.alert = alert
+// withNode calls f with the attached node and returns true, or returns false
+// when no node is attached. The node's modules are not closed while f runs,
+// but they may still be nil. f must not call withNode itself.
+func (s *Server) withNode(f func(node *node.Node)) bool {
+	s.nodeMu.RLock()
+	defer s.nodeMu.RUnlock()
+	if s.node == nil {
+		return false
 	}
+	f(s.node)
+	return true
 }
 
-// hasAlert returns true when the session has an alert.
-func hasAlert(sessionID string) bool {
-	session, _ := getSession(sessionID)
-	if session != nil {
-		return session.alert != ""
+// nodeAttached returns true when a node is attached to the server.
+func (s *Server) nodeAttached() bool {
+	return s.withNode(func(*node.Node) {})
+}
+
+// nodeReleased returns true while the node is detached so that its modules
+// can be loaded again.
+func (s *Server) nodeReleased() bool {
+	s.nodeMu.RLock()
+	defer s.nodeMu.RUnlock()
+	return s.released
+}
+
+// nodeLoaded returns true when the attached node has loaded every module that
+// wallets need.
+func (s *Server) nodeLoaded() bool {
+	loaded := false
+	s.withNode(func(node *node.Node) {
+		loaded = node.ConsensusSet != nil && node.TransactionPool != nil
+	})
+	return loaded
+}
+
+// consensusHeight returns the height of the node's consensus set, or 0 when
+// it is not loaded.
+func (s *Server) consensusHeight() types.BlockHeight {
+	var height types.BlockHeight
+	s.withNode(func(node *node.Node) {
+		if node.ConsensusSet != nil {
+			height = node.ConsensusSet.Height()
+		}
+	})
+	return height
+}
+
+// consensusSynced returns true when the node's consensus set is loaded and
+// synced.
+func (s *Server) consensusSynced() bool {
+	synced := false
+	s.withNode(func(node *node.Node) {
+		synced = node.ConsensusSet != nil && node.ConsensusSet.Synced()
+	})
+	return synced
+}
+
+// attachWallet creates a wallet module in the node's wallet directory and
+// attaches it to the session. The node's modules are not closed while the
+// wallet is created, and the node is released only after it has been
+// attached, so that closing every wallet also closes this one.
+func (s *Server) attachWallet(walletDirName string, sessionID string, create bool) (modules.Wallet, error) {
+	s.nodeMu.RLock()
+	defer s.nodeMu.RUnlock()
+	node, params := s.node, s.params
+	if node == nil || node.ConsensusSet == nil || node.TransactionPool == nil {
+		return nil, errNodeNotLoaded
+	}
+	walletDeps := params.WalletDeps
+	if walletDeps == nil {
+		walletDeps = modules.ProdDependencies
+	}
+	walletDir := filepath.Join(node.Dir, "wallets", walletDirName)
+	_, err := os.Stat(walletDir)
+	if create && err == nil {
+		return nil, fmt.Errorf("%s already exists", walletDirName)
+	} else if !create && checkErrors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s does not exist", walletDirName)
+	}
+	session, err := s.getSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.getWallet() != nil {
+		return nil, errors.New("session already has a wallet loaded")
+	}
+	w, err := wallet.NewCustomWallet(node.ConsensusSet, node.TransactionPool, walletDir, walletDeps)
+	if err != nil {
+		return nil, err
 	}
-	return false
+	session.setWallet(w, walletDirName)
+	return w, nil
 }
 
-// popAlert gets the alert from the session and then clears it from the session.
-func popAlert(sessionID string) string {
-	session, _ := getSession(sessionID)
-	if session != nil {
-		alert := session.alert
-		session.alert = ""
-		return alert
+// newWallet attaches a newly created wallet module to the session.
+func (s *Server) newWallet(walletDirName string, sessionID string) (modules.Wallet, error) {
+	loadStart := time.Now()
+	log := s.log.With(logger.F("session", sessionDigest(sessionID)), logger.F("wallet", walletDirName))
+	log.Info("Creating wallet")
+	w, err := s.attachWallet(walletDirName, sessionID, true)
+	if err != nil {
+		return nil, err
 	}
-	return ""
+	log.Info("Wallet created", logger.F("duration", time.Since(loadStart)))
+	return w, nil
 }
 
-// collapseMenu sets the menu state to collapsed and returns true
-func collapseMenu(sessionID string) bool {
-	session, _ := getSession(sessionID)
-	if session != nil {
-		session.collapseMenu = true
+// existingWallet attaches an existing wallet module to the session.
+func (s *Server) existingWallet(walletDirName string, sessionID string) (modules.Wallet, error) {
+	loadStart := time.Now()
+	log := s.log.With(logger.F("session", sessionDigest(sessionID)), logger.F("wallet", walletDirName))
+	log.Info("Loading wallet")
+	w, err := s.attachWallet(walletDirName, sessionID, false)
+	if err != nil {
+		return nil, err
 	}
-	return true
+	log.Info("Wallet loaded", logger.F("duration", time.Since(loadStart)))
+	return w, nil
 }
 
-// expandMenu sets the menu state to expanded and returns true
-func expandMenu(sessionID string) bool {
-	session, _ := getSession(sessionID)
-	if session != nil {
-		session.collapseMenu = false
+// closeSessionWallet closes the wallet attached to the session, if any.
+func (s *Server) closeSessionWallet(session *Session) error {
+	name := session.getName()
+	wallet := session.setWallet(nil, "")
+	if wallet != nil {
+		s.log.Info("Closing wallet", logger.F("session", sessionDigest(session.id)), logger.F("wallet", name))
+		return wallet.Close()
 	}
-	return true
+	return nil
 }
 
-// menuIsCollapsed returns true when the menu state is collapsed
-func menuIsCollapsed(sessionID string) bool {
-	session, _ := getSession(sessionID)
-	if session != nil {
-		return session.collapseMenu
+// CloseAllWallets closes all wallets and detaches them from the node.
+func (s *Server) CloseAllWallets() (err error) {
+	for _, session := range s.sessions.all() {
+		err = errors.Compose(err, s.closeSessionWallet(session))
 	}
-	// default to the menu being expanded just in case
-	return false
+	return err
 }
 
-// setTxHistoryPage sets the session's transaction history page and returns true.
-func setTxHistoryPage(txHistoryPage int, sessionID string) bool {
-	session, _ := getSession(sessionID)
-	if session != nil {
-		session.txHistoryPage = txHistoryPage
+// logout closes the session's wallet and removes the session from memory.
+func (s *Server) logout(sessionID string) error {
+	session := s.sessions.remove(sessionID)
+	if session == nil {
+		return errSessionNotFound
 	}
-	return true
+	return s.closeSessionWallet(session)
 }
 
-// getTxHistoryPage returns the session's transaction history page or -1 when no session is found.
-func getTxHistoryPage(sessionID string) int {
-	session, _ := getSession(sessionID)
-	if session != nil {
-		return session.txHistoryPage
+// threadedPruneSessions periodically removes expired sessions and closes
+// their wallets until the server is shut down.
+func (s *Server) threadedPruneSessions() {
+	ticker := time.NewTicker(sessionPruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+		}
+		for _, session := range s.sessions.prune() {
+			s.log.Info("Session expired", logger.F("session", sessionDigest(session.id)))
+			err := s.closeSessionWallet(session)
+			if err != nil {
+				s.log.Error("Unable to close expired session's wallet", logger.F("session", sessionDigest(session.id)), logger.Err(err))
+			}
+		}
 	}
-	return -1
 }
 
-// cachedPage caches the page without the menu and returns true.
-func cachedPage(cachedPage string, sessionID string) bool {
-	session, _ := getSession(sessionID)
-	if session != nil {
-		session.cachedPage = cachedPage
+func (s *Server) getWallet(sessionID string) (modules.Wallet, error) {
+	session, err := s.getSession(sessionID)
+	if err != nil {
+		return nil, err
 	}
-	return true
+	wallet := session.getWallet()
+	if wallet == nil {
+		return nil, errors.New("no wallet is attached to the session")
+	}
+	return wallet, nil
 }
 
-// getCachedPage returns the session's cached page.
-func getCachedPage(sessionID string) string {
-	session, _ := getSession(sessionID)
+// updateHeartbeat updates and returns the heartbeat time.
+func (s *Server) updateHeartbeat(sessionID string) time.Time {
+	now := time.Now()
+	s.heartbeatMu.Lock()
+	s.heartbeat = now
+	s.heartbeatMu.Unlock()
+	session, _ := s.getSession(sessionID)
 	if session != nil {
-		return session.cachedPage
+		session.mu.Lock()
+		session.heartbeat = now
+		session.mu.Unlock()
 	}
-	return ""
+	return now
+}
+
+// lastHeartbeat returns when the GUI last sent a heartbeat.
+func (s *Server) lastHeartbeat() time.Time {
+	s.heartbeatMu.Lock()
+	defer s.heartbeatMu.Unlock()
+	return s.heartbeat
 }
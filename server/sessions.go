@@ -0,0 +1,459 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"gitlab.com/scpcorp/webwallet/logger"
+	"gitlab.com/scpcorp/webwallet/resources"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"gitlab.com/scpcorp/ScPrime/modules"
+)
+
+const (
+	// defaultSessionIdleTimeout is how long a session may go without being
+	// used before it expires.
+	defaultSessionIdleTimeout = 30 * time.Minute
+
+	// defaultSessionMaxAge is how long a session may live in total before it
+	// expires.
+	defaultSessionMaxAge = 24 * time.Hour
+
+	// defaultMaxSessions is the maximum number of sessions that may be open
+	// at once.
+	defaultMaxSessions = 32
+
+	// sessionPruneInterval is how often expired sessions are removed.
+	sessionPruneInterval = time.Minute
+)
+
+var (
+	// errSessionNotFound is returned when a session ID is not in the store or
+	// has expired.
+	errSessionNotFound = errors.New("session ID was not found")
+
+	// errTooManySessions is returned when a session is requested while the
+	// maximum number of sessions are already open.
+	errTooManySessions = errors.New("too many open sessions")
+)
+
+// walletState is the long running operation, if any, that is being performed
+// on a session's wallet.
+type walletState int
+
+const (
+	// walletStateIdle means that no operation is running on the wallet.
+	walletStateIdle walletState = iota
+	// walletStateScanning means that the wallet is being unlocked and scanned.
+	walletStateScanning
+	// walletStateInitializing means that a new wallet seed is being
+	// initialized.
+	walletStateInitializing
+	// walletStateRestoring means that the wallet is being restored from a
+	// seed.
+	walletStateRestoring
+)
+
+// String returns the status text shown to the user for the state.
+func (ws walletState) String() string {
+	switch ws {
+	case walletStateScanning:
+		return "Scanning"
+	case walletStateInitializing:
+		return "Initializing"
+	case walletStateRestoring:
+		return "Restoring"
+	}
+	return ""
+}
+
+// Session is a struct that tracks session settings
+type Session struct {
+	id       string
+	created  time.Time
+	lastSeen time.Time
+	// csrfToken is rendered into the session's forms and must be supplied
+	// with every state changing request.
+	csrfToken string
+
+	mu            sync.Mutex
+	alert         string
+	collapseMenu  bool
+	txHistoryPage int
+	cachedPage    resources.Page
+	wallet        modules.Wallet
+	name          string
+	heartbeat     time.Time
+	state         walletState
+	progress      int
+}
+
+// sessionStore is a concurrency safe store of sessions that expire after
+// being idle for too long or after reaching their maximum age.
+type sessionStore struct {
+	mu          sync.Mutex
+	sessions    map[string]*Session
+	idleTimeout time.Duration
+	maxAge      time.Duration
+	maxSessions int
+	// now returns the current time. It is replaced by tests.
+	now func() time.Time
+}
+
+// newSessionStore returns an empty session store.
+func newSessionStore(idleTimeout time.Duration, maxAge time.Duration, maxSessions int) *sessionStore {
+	return &sessionStore{
+		sessions:    make(map[string]*Session),
+		idleTimeout: idleTimeout,
+		maxAge:      maxAge,
+		maxSessions: maxSessions,
+		now:         time.Now,
+	}
+}
+
+// expired returns true when the session has been idle for too long or has
+// reached its maximum age. The store's lock must be held.
+func (ss *sessionStore) expired(session *Session, now time.Time) bool {
+	if ss.idleTimeout > 0 && now.After(session.lastSeen.Add(ss.idleTimeout)) {
+		return true
+	}
+	return ss.maxAge > 0 && now.After(session.created.Add(ss.maxAge))
+}
+
+// add creates a new session and adds it to the store.
+func (ss *sessionStore) add() (*Session, error) {
+	b := make([]byte, 32) //64 characters long
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	now := ss.now()
+	session := &Session{
+		id:            hex.EncodeToString(b[:16]),
+		csrfToken:     hex.EncodeToString(b[16:]),
+		created:       now,
+		lastSeen:      now,
+		collapseMenu:  true,
+		txHistoryPage: 1,
+		progress:      -1,
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.maxSessions > 0 && len(ss.sessions) >= ss.maxSessions {
+		return nil, errTooManySessions
+	}
+	ss.sessions[session.id] = session
+	return session, nil
+}
+
+// get returns the session. Expired sessions are not returned. Getting a
+// session does not mark it as used, since the GUI's background requests get
+// the session without the user being present.
+func (ss *sessionStore) get(sessionID string) (*Session, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	session, ok := ss.sessions[sessionID]
+	if !ok || ss.expired(session, ss.now()) {
+		return nil, errSessionNotFound
+	}
+	return session, nil
+}
+
+// touch marks the session as used so that it does not expire from being
+// idle. Expired sessions are left to be pruned.
+func (ss *sessionStore) touch(sessionID string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	session, ok := ss.sessions[sessionID]
+	now := ss.now()
+	if ok && !ss.expired(session, now) {
+		session.lastSeen = now
+	}
+}
+
+// remove removes the session from the store and returns it, or nil when the
+// session does not exist.
+func (ss *sessionStore) remove(sessionID string) *Session {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	session, ok := ss.sessions[sessionID]
+	if !ok {
+		return nil
+	}
+	delete(ss.sessions, sessionID)
+	return session
+}
+
+// prune removes all expired sessions from the store and returns them so that
+// their wallets can be closed.
+func (ss *sessionStore) prune() []*Session {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	now := ss.now()
+	var expired []*Session
+	for id, session := range ss.sessions {
+		if ss.expired(session, now) {
+			delete(ss.sessions, id)
+			expired = append(expired, session)
+		}
+	}
+	return expired
+}
+
+// all returns every session in the store.
+func (ss *sessionStore) all() []*Session {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	sessions := make([]*Session, 0, len(ss.sessions))
+	for _, session := range ss.sessions {
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// len returns the number of sessions in the store.
+func (ss *sessionStore) len() int {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return len(ss.sessions)
+}
+
+// getWallet returns the wallet attached to the session.
+func (session *Session) getWallet() modules.Wallet {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.wallet
+}
+
+// setWallet attaches the wallet to the session and returns the wallet that
+// was previously attached.
+func (session *Session) setWallet(wallet modules.Wallet, name string) modules.Wallet {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	prev := session.wallet
+	session.wallet = wallet
+	session.name = name
+	return prev
+}
+
+// getName returns the name of the wallet attached to the session.
+func (session *Session) getName() string {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.name
+}
+
+// getState returns the operation running on the session's wallet and its
+// progress as a percentage, or -1 when the progress is unknown.
+func (session *Session) getState() (walletState, int) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.state, session.progress
+}
+
+// setState sets the operation running on the session's wallet and resets its
+// progress.
+func (session *Session) setState(state walletState) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.state = state
+	session.progress = -1
+}
+
+// setProgress sets the progress of the operation running on the session's
+// wallet.
+func (session *Session) setProgress(progress int) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.progress = progress
+}
+
+// addSessionID adds a new session to memory and returns its ID.
+func (s *Server) addSessionID() (string, error) {
+	session, err := s.sessions.add()
+	if err != nil {
+		return "", err
+	}
+	return session.id, nil
+}
+
+// getSession returns the session.
+func (s *Server) getSession(sessionID string) (*Session, error) {
+	return s.sessions.get(sessionID)
+}
+
+// sessionIDExists returns true when the supplied session ID exists in memory.
+func (s *Server) sessionIDExists(sessionID string) bool {
+	session, _ := s.getSession(sessionID)
+	return session != nil
+}
+
+// csrfToken returns the session's CSRF token, or an empty string when no
+// session is found.
+func (s *Server) csrfToken(sessionID string) string {
+	session, _ := s.getSession(sessionID)
+	if session != nil {
+		return session.csrfToken
+	}
+	return ""
+}
+
+// setStatus sets the operation running on the session's wallet.
+func (s *Server) setStatus(sessionID string, state walletState) {
+	session, _ := s.getSession(sessionID)
+	if session != nil {
+		session.setState(state)
+	}
+}
+
+// getStatus returns the operation running on the session's wallet.
+func (s *Server) getStatus(sessionID string) walletState {
+	session, _ := s.getSession(sessionID)
+	if session != nil {
+		state, _ := session.getState()
+		return state
+	}
+	return walletStateIdle
+}
+
+// setAlert sets an alert on the session.
+func (s *Server) setAlert(alert string, sessionID string) {
+	session, _ := s.getSession(sessionID)
+	if session != nil {
+		session.mu.Lock()
+		session.alert = alert
+		session.mu.Unlock()
+	}
+}
+
+// hasAlert returns true when the session has an alert.
+func (s *Server) hasAlert(sessionID string) bool {
+	session, _ := s.getSession(sessionID)
+	if session != nil {
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		return session.alert != ""
+	}
+	return false
+}
+
+// popAlert gets the alert from the session and then clears it from the session.
+func (s *Server) popAlert(sessionID string) string {
+	session, _ := s.getSession(sessionID)
+	if session != nil {
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		alert := session.alert
+		session.alert = ""
+		return alert
+	}
+	return ""
+}
+
+// collapseMenu sets the menu state to collapsed and returns true
+func (s *Server) collapseMenu(sessionID string) bool {
+	session, _ := s.getSession(sessionID)
+	if session != nil {
+		session.mu.Lock()
+		session.collapseMenu = true
+		session.mu.Unlock()
+	}
+	return true
+}
+
+// expandMenu sets the menu state to expanded and returns true
+func (s *Server) expandMenu(sessionID string) bool {
+	session, _ := s.getSession(sessionID)
+	if session != nil {
+		session.mu.Lock()
+		session.collapseMenu = false
+		session.mu.Unlock()
+	}
+	return true
+}
+
+// menuIsCollapsed returns true when the menu state is collapsed
+func (s *Server) menuIsCollapsed(sessionID string) bool {
+	session, _ := s.getSession(sessionID)
+	if session != nil {
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		return session.collapseMenu
+	}
+	// default to the menu being expanded just in case
+	return false
+}
+
+// setTxHistoryPage sets the session's transaction history page and returns true.
+func (s *Server) setTxHistoryPage(txHistoryPage int, sessionID string) bool {
+	session, _ := s.getSession(sessionID)
+	if session != nil {
+		session.mu.Lock()
+		session.txHistoryPage = txHistoryPage
+		session.mu.Unlock()
+	}
+	return true
+}
+
+// getTxHistoryPage returns the session's transaction history page or -1 when no session is found.
+func (s *Server) getTxHistoryPage(sessionID string) int {
+	session, _ := s.getSession(sessionID)
+	if session != nil {
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		return session.txHistoryPage
+	}
+	return -1
+}
+
+// cachedPage caches the page so that it can be shown again with a new header
+// and returns true.
+func (s *Server) cachedPage(page resources.Page, sessionID string) bool {
+	session, _ := s.getSession(sessionID)
+	if session != nil {
+		session.mu.Lock()
+		session.cachedPage = page
+		session.mu.Unlock()
+	}
+	return true
+}
+
+// getCachedPage returns the session's cached page or nil when there is none.
+func (s *Server) getCachedPage(sessionID string) resources.Page {
+	session, _ := s.getSession(sessionID)
+	if session != nil {
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		return session.cachedPage
+	}
+	return nil
+}
+
+// sessionDigest identifies the session in log messages. The session ID itself
+// grants access to the session's wallet, so only a short digest of it is
+// logged.
+func sessionDigest(sessionID string) string {
+	if sessionID == "" {
+		return "none"
+	}
+	digest := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(digest[:4])
+}
+
+// sessionLog returns a logger that adds the session and the name of its
+// wallet to every message.
+func (s *Server) sessionLog(sessionID string) logger.Logger {
+	log := s.log.With(logger.F("session", sessionDigest(sessionID)))
+	session, err := s.getSession(sessionID)
+	if err == nil {
+		if name := session.getName(); name != "" {
+			log = log.With(logger.F("wallet", name))
+		}
+	}
+	return log
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when it is advanced.
+type fakeClock struct {
+	now time.Time
+}
+
+// Now returns the clock's time.
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// advance moves the clock forward by d.
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// newTestSessionStore returns a session store that uses the fake clock.
+func newTestSessionStore(clock *fakeClock, idleTimeout time.Duration, maxAge time.Duration, maxSessions int) *sessionStore {
+	ss := newSessionStore(idleTimeout, maxAge, maxSessions)
+	ss.now = clock.Now
+	return ss
+}
+
+// TestSessionStoreExpiry tests that sessions expire after being idle for too
+// long or after reaching their maximum age, and that using a session keeps it
+// from going idle.
+func TestSessionStoreExpiry(t *testing.T) {
+	tests := []struct {
+		name string
+		// uses are the times after the session was created at which it is
+		// used before it is looked up at lookup.
+		uses    []time.Duration
+		lookup  time.Duration
+		expired bool
+	}{
+		{name: "fresh", lookup: time.Minute},
+		{name: "just before idle timeout", lookup: 10 * time.Minute},
+		{name: "idle", lookup: 10*time.Minute + time.Second, expired: true},
+		{name: "used before idle timeout", uses: []time.Duration{9 * time.Minute}, lookup: 18 * time.Minute},
+		{name: "idle after last use", uses: []time.Duration{9 * time.Minute}, lookup: 19*time.Minute + time.Second, expired: true},
+		{name: "max age", uses: []time.Duration{9 * time.Minute, 18 * time.Minute, 27 * time.Minute}, lookup: 30*time.Minute + time.Second, expired: true},
+		{name: "just before max age", uses: []time.Duration{9 * time.Minute, 18 * time.Minute, 27 * time.Minute}, lookup: 30 * time.Minute},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Unix(1600000000, 0)}
+			created := clock.now
+			ss := newTestSessionStore(clock, 10*time.Minute, 30*time.Minute, 0)
+			session, err := ss.add()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, use := range test.uses {
+				clock.now = created.Add(use)
+				if _, err := ss.get(session.id); err != nil {
+					t.Fatalf("session expired at %v: %v", use, err)
+				}
+				ss.touch(session.id)
+			}
+			clock.now = created.Add(test.lookup)
+			_, err = ss.get(session.id)
+			if test.expired && err != errSessionNotFound {
+				t.Fatalf("expected %v, got %v", errSessionNotFound, err)
+			}
+			if !test.expired && err != nil {
+				t.Fatalf("expected the session, got %v", err)
+			}
+		})
+	}
+}
+
+// TestSessionStoreGetDoesNotTouch tests that only touching a session keeps it
+// from going idle, so that the GUI's background requests, which only get the
+// session, do not keep it open forever, and that an expired session cannot be
+// touched back to life.
+func TestSessionStoreGetDoesNotTouch(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1600000000, 0)}
+	ss := newTestSessionStore(clock, 10*time.Minute, 0, 0)
+	session, err := ss.add()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		clock.advance(time.Minute)
+		if _, err := ss.get(session.id); err != nil {
+			t.Fatalf("session expired after %v minutes: %v", i+1, err)
+		}
+	}
+	clock.advance(time.Second)
+	if _, err := ss.get(session.id); err != errSessionNotFound {
+		t.Fatalf("expected %v, got %v", errSessionNotFound, err)
+	}
+	ss.touch(session.id)
+	if _, err := ss.get(session.id); err != errSessionNotFound {
+		t.Fatalf("expected the expired session to stay expired, got %v", err)
+	}
+}
+
+// TestSessionStoreLimit tests that no more than the maximum number of
+// sessions can be open at once.
+func TestSessionStoreLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxSessions int
+		add         int
+		wantErr     bool
+	}{
+		{name: "below limit", maxSessions: 3, add: 2},
+		{name: "at limit", maxSessions: 3, add: 3},
+		{name: "above limit", maxSessions: 3, add: 4, wantErr: true},
+		{name: "unlimited", maxSessions: 0, add: 100},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ss := newTestSessionStore(&fakeClock{now: time.Unix(1600000000, 0)}, time.Hour, 0, test.maxSessions)
+			var err error
+			for i := 0; i < test.add && err == nil; i++ {
+				_, err = ss.add()
+			}
+			if test.wantErr && err != errTooManySessions {
+				t.Fatalf("expected %v, got %v", errTooManySessions, err)
+			}
+			if !test.wantErr && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// TestSessionStoreLimitFreedByRemoval tests that removing or pruning a
+// session makes room for a new one.
+func TestSessionStoreLimitFreedByRemoval(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1600000000, 0)}
+	ss := newTestSessionStore(clock, time.Minute, 0, 2)
+	first, err := ss.add()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ss.add(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ss.add(); err != errTooManySessions {
+		t.Fatalf("expected %v, got %v", errTooManySessions, err)
+	}
+	if ss.remove(first.id) != first {
+		t.Fatal("remove did not return the session")
+	}
+	if ss.remove(first.id) != nil {
+		t.Fatal("removed session was removed again")
+	}
+	if _, err := ss.add(); err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(time.Minute + time.Second)
+	if n := len(ss.prune()); n != 2 {
+		t.Fatalf("expected 2 pruned sessions, got %v", n)
+	}
+	if _, err := ss.add(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSessionStorePrune tests that pruning removes and returns exactly the
+// expired sessions.
+func TestSessionStorePrune(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1600000000, 0)}
+	ss := newTestSessionStore(clock, 10*time.Minute, time.Hour, 0)
+	idle, err := ss.add()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(5 * time.Minute)
+	active, err := ss.add()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(6 * time.Minute)
+	ss.touch(active.id)
+
+	pruned := ss.prune()
+	if len(pruned) != 1 || pruned[0] != idle {
+		t.Fatalf("expected only the idle session to be pruned, got %v sessions", len(pruned))
+	}
+	if ss.len() != 1 {
+		t.Fatalf("expected 1 session to remain, got %v", ss.len())
+	}
+	if _, err := ss.get(idle.id); err != errSessionNotFound {
+		t.Fatalf("expected %v for the pruned session, got %v", errSessionNotFound, err)
+	}
+	if len(ss.prune()) != 0 {
+		t.Fatal("nothing should be pruned twice")
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	"gitlab.com/scpcorp/webwallet/logger"
+	"gitlab.com/scpcorp/webwallet/resources"
+)
+
+// StartupAction is what the user chose to do after a node module failed to
+// load.
+type StartupAction int
+
+const (
+	// StartupRetry loads the failed module and the modules after it again.
+	StartupRetry StartupAction = iota
+	// StartupResetConsensus deletes the consensus set before loading it
+	// again, for when its database is corrupt.
+	StartupResetConsensus
+)
+
+// String returns the name of the action.
+func (a StartupAction) String() string {
+	if a == StartupResetConsensus {
+		return "reset consensus"
+	}
+	return "retry"
+}
+
+// moduleTitles are the names of the node modules as they are shown to the
+// user.
+var moduleTitles = map[string]string{
+	ModuleGateway:         "gateway",
+	ModuleConsensusSet:    "consensus set",
+	ModuleTransactionPool: "transaction pool",
+}
+
+// StartupActions returns the channel that receives the action the user chose
+// in the GUI after a node module failed to load.
+func (s *Server) StartupActions() <-chan StartupAction {
+	return s.startupActions
+}
+
+// ReleaseNode detaches the node from the server and closes every wallet, so
+// that the node's consensus set and transaction pool can be closed and loaded
+// again. The starting page is served until the node is attached again.
+func (s *Server) ReleaseNode() error {
+	s.detachNode()
+	return s.CloseAllWallets()
+}
+
+// ClearModuleFailures marks the modules that failed to load as not loaded.
+// The daemon calls it once it has closed them so that they can be loaded
+// again.
+func (s *Server) ClearModuleFailures() {
+	for _, module := range s.modules.all() {
+		if module.State == ModuleFailed {
+			s.SetModuleState(module.Name, ModuleNotLoaded, nil)
+		}
+	}
+}
+
+// CanResetConsensus reports whether the consensus set is the module that
+// failed to load. Resetting the consensus set does not fix the failures of
+// the other modules, so it is only allowed then.
+func (s *Server) CanResetConsensus() bool {
+	failure, failed := s.startupFailure()
+	return failed && failure.Name == ModuleConsensusSet
+}
+
+// startupFailure returns the first node module that failed to load, if any.
+func (s *Server) startupFailure() (ModuleStatus, bool) {
+	for _, module := range s.modules.all() {
+		if module.State == ModuleFailed {
+			return module, true
+		}
+	}
+	return ModuleStatus{}, false
+}
+
+// writeStartupFailure writes the page that shows which module failed to load
+// and why, and lets the user retry.
+func (s *Server) writeStartupFailure(w http.ResponseWriter, req *http.Request, failure ModuleStatus) {
+	s.writePage(w, resources.StartupFailedPage{
+		Module:         moduleTitles[failure.Name],
+		Error:          failure.Error,
+		ResetConsensus: failure.Name == ModuleConsensusSet,
+		CSRFToken:      s.formCSRFToken(req),
+	})
+}
+
+// startupRetryHandler loads the failed modules again.
+func (s *Server) startupRetryHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	s.startupActionHandler(w, req, StartupRetry)
+}
+
+// startupResetConsensusHandler deletes the consensus set and loads it again.
+func (s *Server) startupResetConsensusHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	s.startupActionHandler(w, req, StartupResetConsensus)
+}
+
+// startupActionHandler hands the action to the daemon when a module has
+// failed to load. The daemon marks the failed modules as not loaded once it
+// has closed them.
+func (s *Server) startupActionHandler(w http.ResponseWriter, req *http.Request, action StartupAction) {
+	failure, failed := s.startupFailure()
+	if !failed {
+		redirect(w, req, nil)
+		return
+	}
+	if action == StartupResetConsensus && !s.CanResetConsensus() {
+		http.Error(w, "the consensus set did not fail to load", http.StatusBadRequest)
+		return
+	}
+	select {
+	case s.startupActions <- action:
+		s.log.Info("Node startup action requested", logger.F("action", action), logger.F("module", failure.Name))
+	default:
+		// An action is already pending.
+	}
+	redirect(w, req, nil)
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestStartupResetConsensus tests that the consensus set is only reset when
+// it is the module that failed to load.
+func TestStartupResetConsensus(t *testing.T) {
+	tests := []struct {
+		name   string
+		failed string
+		want   int
+		reset  bool
+	}{
+		{name: "nothing failed", want: http.StatusMovedPermanently},
+		{name: "gateway failed", failed: ModuleGateway, want: http.StatusBadRequest},
+		{name: "transaction pool failed", failed: ModuleTransactionPool, want: http.StatusBadRequest},
+		{name: "consensus set failed", failed: ModuleConsensusSet, want: http.StatusMovedPermanently, reset: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServer(t)
+			if test.failed != "" {
+				s.SetModuleState(test.failed, ModuleFailed, errors.New("failed"))
+			}
+			w := httptest.NewRecorder()
+			s.startupResetConsensusHandler(w, httptest.NewRequest(http.MethodPost, "/startup/resetConsensus", nil), nil)
+			if w.Code != test.want {
+				t.Fatalf("expected status %v, got %v", test.want, w.Code)
+			}
+			select {
+			case action := <-s.StartupActions():
+				if !test.reset || action != StartupResetConsensus {
+					t.Fatalf("unexpected action %v", action)
+				}
+			default:
+				if test.reset {
+					t.Fatal("the consensus set was not reset")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	spdBuild "gitlab.com/scpcorp/ScPrime/build"
+	"gitlab.com/scpcorp/ScPrime/node"
+	"gitlab.com/scpcorp/ScPrime/types"
+
+	"gitlab.com/scpcorp/webwallet/build"
+	"gitlab.com/scpcorp/webwallet/modules/bootstrapper"
+	consensusbuilder "gitlab.com/scpcorp/webwallet/modules/consensesbuilder"
+)
+
+// ModuleState is the load state of a node module.
+type ModuleState string
+
+const (
+	// ModuleNotLoaded is the state of a module that has not started loading.
+	ModuleNotLoaded ModuleState = "not_loaded"
+	// ModuleLoading is the state of a module that is being loaded.
+	ModuleLoading ModuleState = "loading"
+	// ModuleLoaded is the state of a module that has been loaded.
+	ModuleLoaded ModuleState = "loaded"
+	// ModuleFailed is the state of a module that failed to load.
+	ModuleFailed ModuleState = "failed"
+)
+
+// Names of the node modules whose load state is reported.
+const (
+	ModuleGateway         = "gateway"
+	ModuleConsensusSet    = "consensus"
+	ModuleTransactionPool = "transactionpool"
+)
+
+// nodeModules are the modules the node loads, in load order.
+var nodeModules = []string{ModuleGateway, ModuleConsensusSet, ModuleTransactionPool}
+
+// Health statuses reported by the health endpoint.
+const (
+	healthOK       = "ok"
+	healthStarting = "starting"
+	healthFailed   = "failed"
+)
+
+type (
+	// HealthResponse is returned by the health endpoint. Ready is true once
+	// every module has been loaded.
+	HealthResponse struct {
+		Status string `json:"status"`
+		Ready  bool   `json:"ready"`
+	}
+
+	// ModuleStatus is the load state of a module and the error it failed
+	// with, if any.
+	ModuleStatus struct {
+		Name  string      `json:"name"`
+		State ModuleState `json:"state"`
+		Error string      `json:"error,omitempty"`
+	}
+
+	// StatusResponse is returned by the status endpoint.
+	StatusResponse struct {
+		HealthResponse
+		Version          string            `json:"version"`
+		GitRevision      string            `json:"git_revision"`
+		SPDVersion       string            `json:"spd_version"`
+		Modules          []ModuleStatus    `json:"modules"`
+		Height           types.BlockHeight `json:"height"`
+		Synced           bool              `json:"synced"`
+		Peers            int               `json:"peers"`
+		Bootstrapper     string            `json:"bootstrapper"`
+		ConsensusBuilder string            `json:"consensus_builder"`
+		OpenWallets      int               `json:"open_wallets"`
+		Startup          []PhaseProgress   `json:"startup"`
+	}
+)
+
+// moduleStates holds the load state of every node module and how long the
+// modules took to load.
+type moduleStates struct {
+	mu        sync.Mutex
+	statuses  map[string]ModuleStatus
+	started   map[string]time.Time
+	loadTimes map[string]time.Duration
+}
+
+// newModuleStates returns the states of the node modules before any of them
+// has been loaded.
+func newModuleStates() *moduleStates {
+	ms := &moduleStates{
+		statuses:  make(map[string]ModuleStatus),
+		started:   make(map[string]time.Time),
+		loadTimes: make(map[string]time.Duration),
+	}
+	for _, name := range nodeModules {
+		ms.statuses[name] = ModuleStatus{Name: name, State: ModuleNotLoaded}
+	}
+	return ms
+}
+
+// all returns the states of the node modules in load order.
+func (ms *moduleStates) all() []ModuleStatus {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	statuses := make([]ModuleStatus, 0, len(nodeModules))
+	for _, name := range nodeModules {
+		statuses = append(statuses, ms.statuses[name])
+	}
+	return statuses
+}
+
+// loadTime returns how long the module took to load, if it was loaded while
+// its state was being tracked.
+func (ms *moduleStates) loadTime(module string) (time.Duration, bool) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	d, ok := ms.loadTimes[module]
+	return d, ok
+}
+
+// SetModuleState records the load state of the module, and the error it
+// failed with when the state is ModuleFailed.
+func (s *Server) SetModuleState(module string, state ModuleState, err error) {
+	status := ModuleStatus{Name: module, State: state}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	s.modules.mu.Lock()
+	defer s.modules.mu.Unlock()
+	s.modules.statuses[module] = status
+	switch state {
+	case ModuleLoading:
+		s.modules.started[module] = time.Now()
+		delete(s.modules.loadTimes, module)
+	case ModuleLoaded:
+		if started, ok := s.modules.started[module]; ok {
+			s.modules.loadTimes[module] = time.Since(started)
+		}
+	}
+}
+
+// markLoadedModules marks the modules of an already loaded node as loaded.
+func (s *Server) markLoadedModules() {
+	s.withNode(func(node *node.Node) {
+		if node.Gateway != nil {
+			s.SetModuleState(ModuleGateway, ModuleLoaded, nil)
+		}
+		if node.ConsensusSet != nil {
+			s.SetModuleState(ModuleConsensusSet, ModuleLoaded, nil)
+		}
+		if node.TransactionPool != nil {
+			s.SetModuleState(ModuleTransactionPool, ModuleLoaded, nil)
+		}
+	})
+}
+
+// health returns the health of the node from the module states.
+func health(modules []ModuleStatus) HealthResponse {
+	h := HealthResponse{Status: healthOK, Ready: true}
+	for _, module := range modules {
+		switch module.State {
+		case ModuleFailed:
+			return HealthResponse{Status: healthFailed}
+		case ModuleLoaded:
+		default:
+			h = HealthResponse{Status: healthStarting}
+		}
+	}
+	return h
+}
+
+// openWallets returns the number of sessions that have a wallet attached.
+func (s *Server) openWallets() int {
+	n := 0
+	for _, session := range s.sessions.all() {
+		if session.getWallet() != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// healthHandler reports whether the web wallet is healthy and ready. It
+// responds with 503 Service Unavailable once a module has failed to load.
+func (s *Server) healthHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	h := health(s.modules.all())
+	code := http.StatusOK
+	if h.Status == healthFailed {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, h)
+}
+
+// statusHandler reports the load state of the node modules, the state of the
+// consensus set and the network, the startup phases and the version.
+func (s *Server) statusHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	modules := s.modules.all()
+	status := StatusResponse{
+		HealthResponse:   health(modules),
+		Version:          build.Version,
+		GitRevision:      build.GitRevision,
+		SPDVersion:       spdBuild.Version,
+		Modules:          modules,
+		Bootstrapper:     bootstrapper.Progress(),
+		ConsensusBuilder: consensusbuilder.Progress(),
+		OpenWallets:      s.openWallets(),
+		Startup:          s.StartupProgress(),
+	}
+	s.withNode(func(node *node.Node) {
+		if node.ConsensusSet != nil {
+			status.Height = node.ConsensusSet.Height()
+			status.Synced = node.ConsensusSet.Synced()
+		}
+		if node.Gateway != nil {
+			status.Peers = len(node.Gateway.Peers())
+		}
+	})
+	writeJSON(w, http.StatusOK, status)
+}
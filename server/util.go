@@ -36,6 +36,10 @@ var (
 	ErrUint64Overflow = errors.New("cannot return the uint64 of this currency - result is an overflow")
 	// ZeroCurrency defines a currency of value zero.
 	ZeroCurrency = types.NewCurrency64(0)
+
+	// errInvalidCoinType is returned when a send is requested for a coin type
+	// other than SCP or SPF.
+	errInvalidCoinType = errors.New("coin type was not supplied")
 )
 
 // SummarizedTransaction is a transaction that has been formatted for·